@@ -0,0 +1,283 @@
+package main
+
+/*
+運用作業のための管理者用CLI
+ go run ./admin [--dry-run] <command> [args]
+
+ ・repository / usecase を通して操作するので、APIと同じ検証がかかる
+ ・1つのコマンドは1つのトランザクションで実行し、--dry-run の場合は最後にロールバックする
+ ・結果はコミットした後に出力する（トランザクションをやり直した場合に二重に出力しない）
+ ・パスワードはシェルの履歴やpsに残らないよう、引数ではなく環境変数 ADMIN_PASSWORD か標準入力から受け取る
+*/
+
+import (
+	"bufio"
+	"bulletin-board-rest-api/config"
+	"bulletin-board-rest-api/db"
+	"bulletin-board-rest-api/logger"
+	"bulletin-board-rest-api/mailer"
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"bulletin-board-rest-api/usecase"
+	"bulletin-board-rest-api/validator"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+	"gorm.io/gorm"
+)
+
+const usage = `usage: admin [--dry-run] <command> [args]
+
+commands:
+  create-admin -email E -username U              create a new admin user
+  promote USER                                   make an existing user an admin
+  reset-password USER                            set a new password (generated unless ADMIN_PASSWORD is set)
+  suspend USER                                   block login and revoke sessions
+  unsuspend USER                                 allow the user to log in again
+  transfer-quests [-quest ID] FROM TO            change the owner of FROM's quests
+  purge [-notifications-older-than DURATION]     delete expired tokens, counters and old read notifications
+  stats                                          print usage statistics
+
+USER is an email address or a user name.
+create-admin takes the password from ADMIN_PASSWORD, or prompts for it
+(the first line of stdin is used when stdin is not a terminal).
+`
+
+var (
+	errUsage  = errors.New("invalid arguments")
+	errDryRun = errors.New("dry run") // ロールバックさせるためのエラー
+)
+
+var commands = map[string]bool{
+	"create-admin": true, "promote": true, "reset-password": true, "suspend": true,
+	"unsuspend": true, "transfer-quests": true, "purge": true, "stats": true,
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "run the command and roll back instead of committing")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 || !commands[args[0]] {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// 入力待ちの間にトランザクションを開いたままにしないよう、パスワードは最初に読む
+	password, err := readPassword(args[0])
+	if err != nil {
+		exit(err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		exit(err)
+	}
+	slog.SetDefault(logger.New(cfg.LogLevel))
+	dbConn := db.NewDB(cfg.DB)
+	defer db.CloseDB(dbConn)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM) // Ctrl+Cで中断するとロールバックされる
+	defer stop()
+
+	// 途中で失敗した場合や --dry-run の場合はロールバックする
+	tm := repository.NewTransactionManager(dbConn)
+	au := newAdminUsecase(dbConn, cfg, tm)
+	var out bytes.Buffer
+	err = tm.Do(ctx, func(ctx context.Context) error {
+		out.Reset() // やり直した場合は前回の出力を捨てる
+		if err := run(ctx, au, &out, args, password); err != nil {
+			return err
+		}
+		if *dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(os.Stderr, usage)
+		db.CloseDB(dbConn)
+		os.Exit(2)
+	}
+	if err != nil {
+		db.CloseDB(dbConn)
+		exit(err)
+	}
+	os.Stdout.Write(out.Bytes())
+	if *dryRun {
+		fmt.Println("dry run: no changes were committed")
+	}
+}
+
+func newAdminUsecase(dbConn *gorm.DB, cfg *config.Config, tm repository.ITransactionManager) usecase.IAdminUsecase {
+	ur := repository.NewUserRepository(dbConn)
+	qr := repository.NewQuestRepository(dbConn)
+	nr := repository.NewNotificationRepository(dbConn)
+	ar := repository.NewAdminRepository(dbConn)
+	uv := validator.NewUserValidator()
+	uu := usecase.NewUserUsecase(ur, qr, nr, uv, mailer.NewMailer(config.SMTPConfig{}), cfg, tm)
+	return usecase.NewAdminUsecase(ur, qr, ar, uu, uv, tm)
+}
+
+func run(ctx context.Context, au usecase.IAdminUsecase, w io.Writer, args []string, password string) error {
+	command, args := args[0], args[1:]
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	switch command {
+	case "create-admin":
+		email := fs.String("email", "", "email address")
+		userName := fs.String("username", "", "user name")
+		if err := parse(fs, args, 0); err != nil {
+			return err
+		}
+		res, err := au.CreateAdmin(ctx, model.User{Email: *email, UserName: *userName, Password: password})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "created admin %s (id=%d)\n", res.UserName, res.ID)
+	case "promote":
+		if err := parse(fs, args, 1); err != nil {
+			return err
+		}
+		res, err := au.PromoteAdmin(ctx, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "promoted %s (id=%d) to admin\n", res.UserName, res.ID)
+	case "reset-password":
+		if err := parse(fs, args, 1); err != nil {
+			return err
+		}
+		newPassword, err := au.ResetPassword(ctx, fs.Arg(0), password)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "password reset for %s; existing sessions were revoked\n", fs.Arg(0))
+		if password == "" {
+			fmt.Fprintln(w, "new password:", newPassword)
+		}
+	case "suspend", "unsuspend":
+		if err := parse(fs, args, 1); err != nil {
+			return err
+		}
+		res, err := au.SetSuspended(ctx, fs.Arg(0), command == "suspend")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%sed %s (id=%d)\n", command, res.UserName, res.ID)
+	case "transfer-quests":
+		questId := fs.Uint("quest", 0, "transfer only this quest")
+		if err := parse(fs, args, 2); err != nil {
+			return err
+		}
+		n, err := au.TransferQuests(ctx, fs.Arg(0), fs.Arg(1), *questId)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "transferred %d quest(s) from %s to %s\n", n, fs.Arg(0), fs.Arg(1))
+	case "purge":
+		age := fs.Duration("notifications-older-than", 90*24*time.Hour, "delete read notifications older than this")
+		if err := parse(fs, args, 0); err != nil {
+			return err
+		}
+		res, err := au.Purge(ctx, *age)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "rate limit counters: %d\n", res.RateLimits)
+		fmt.Fprintf(w, "invites:             %d\n", res.Invites)
+		fmt.Fprintf(w, "email tokens:        %d\n", res.EmailTokens)
+		fmt.Fprintf(w, "notifications:       %d\n", res.Notifications)
+	case "stats":
+		if err := parse(fs, args, 0); err != nil {
+			return err
+		}
+		s, err := au.GetStats(ctx)
+		if err != nil {
+			return err
+		}
+		for _, row := range []struct {
+			label string
+			value int64
+		}{
+			{"users", s.Users}, {"admins", s.Admins}, {"suspended users", s.SuspendedUsers},
+			{"quests", s.Quests}, {"upcoming quests", s.UpcomingQuests},
+			{"participations", s.Participations}, {"pending requests", s.PendingRequests},
+			{"tags", s.Tags}, {"categories", s.Categories},
+		} {
+			fmt.Fprintf(w, "%-17s %d\n", row.label+":", row.value)
+		}
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+// コマンドごとのフラグを読み、位置引数の数を確認する（フラグは位置引数より前に書く）
+func parse(fs *flag.FlagSet, args []string, positional int) error {
+	if err := fs.Parse(args); err != nil || fs.NArg() != positional {
+		return errUsage
+	}
+	return nil
+}
+
+/*
+パスワードを受け取る
+
+	・ADMIN_PASSWORD があればそれを使う
+	・create-admin では、端末なら表示せずに2回入力させ、端末でなければ標準入力の1行目を使う
+	・reset-password では、ADMIN_PASSWORD がなければ空にして生成させる
+*/
+func readPassword(command string) (string, error) {
+	if password, ok := os.LookupEnv("ADMIN_PASSWORD"); ok {
+		return password, nil
+	}
+	if command != "create-admin" {
+		return "", nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	password, err := prompt(fd, "password: ")
+	if err != nil {
+		return "", err
+	}
+	confirm, err := prompt(fd, "confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirm {
+		return "", errors.New("passwords do not match")
+	}
+	return password, nil
+}
+
+func prompt(fd int, label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr) // 入力した改行は表示されないので改行する
+	return string(b), err
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "admin:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,296 @@
+package config
+
+/*
+設定の読み込みと検証
+ 1. 環境変数を読み込む（GO_ENV=devなら.env、CONFIG_FILEを指定すればそのファイルも読む）
+ 2. XXX_FILE が指定されていれば、そのファイルの中身を XXX の値として使う（Dockerのsecrets用）
+ 3. 必須項目や値の形式を確認し、問題があれば起動時にまとめてエラーを返す
+*/
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const minSecretLength = 32 // JWTの署名鍵の最低の長さ（HS256なので256bit）
+
+type Config struct {
+	Env             string // GO_ENV（dev / prod）
+	Port            string // APIサーバーのポート
+	Secret          string // JWTの署名鍵
+	FEURL           string // フロントエンドのURL（CORSとメールのリンクに使う）
+	DB              DBConfig
+	SMTP            SMTPConfig
+	RateLimitStore  string       // memory / postgres
+	LogLevel        string       // debug / info / warn / error
+	MetricsAddr     string       // 指定した場合はメトリクスを別のアドレスで公開する
+	MetricsToken    string       // 指定した場合は /metrics をトークン付きで公開する
+	TrustedProxies  []*net.IPNet // X-Forwarded-Forを信頼するプロキシ（空ならTCPの接続元をクライアントのIPとする）
+	ShutdownTimeout time.Duration
+	DrainDelay      time.Duration            // /readyzを503にしてからHTTPサーバーを止めるまでの待ち時間（ShutdownTimeoutに含まれる）
+	RequestTimeout  time.Duration            // リクエストごとの処理時間の上限（0なら無制限）
+	RouteTimeouts   map[string]time.Duration // ルートごとの上限（"GET /quests" の形式で指定したもの）
+}
+
+type DBConfig struct {
+	User             string
+	Password         string
+	Host             string
+	Port             string
+	Name             string
+	SSLMode          string        // disable / require / verify-full など
+	Options          url.Values    // その他の接続オプション（application_name など）
+	SlowQuery        time.Duration // これより遅いクエリは警告ログを出す
+	StatementTimeout time.Duration // 1つのクエリの最大実行時間（0なら無制限）
+	MaxOpenConns     int
+	MaxIdleConns     int
+	ConnMaxLifetime  time.Duration
+	ConnMaxIdleTime  time.Duration
+	ConnectRetries   int           // 起動時に接続できなかった場合の再試行回数
+	ConnectBackoff   time.Duration // 再試行の最初の待ち時間（毎回2倍にする）
+}
+
+type SMTPConfig struct {
+	Host     string // 空ならメールを送らずログに出力する
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+// 設定を読み込み、検証する
+func Load() (*Config, error) {
+	files, err := readFiles()
+	if err != nil {
+		return nil, err
+	}
+	r := &reader{files: files}
+	cfg := &Config{
+		Env:    r.get("GO_ENV", "prod"),
+		Port:   r.get("PORT", "8000"),
+		Secret: r.get("SECRET", ""),
+		FEURL:  r.get("FE_URL", ""),
+		DB: DBConfig{
+			User:             r.get("POSTGRES_USER", ""),
+			Password:         r.get("POSTGRES_PW", ""),
+			Host:             r.get("POSTGRES_HOST", ""),
+			Port:             r.get("POSTGRES_PORT", "5432"),
+			Name:             r.get("POSTGRES_DB", ""),
+			SSLMode:          r.get("POSTGRES_SSLMODE", "prefer"),
+			Options:          r.query("POSTGRES_OPTIONS"),
+			SlowQuery:        r.millis("DB_SLOW_QUERY_MS", 200),
+			StatementTimeout: r.millis("DB_STATEMENT_TIMEOUT_MS", 30000),
+			MaxOpenConns:     r.int("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:     r.int("DB_MAX_IDLE_CONNS", 10),
+			ConnMaxLifetime:  r.millis("DB_CONN_MAX_LIFETIME_MS", 30*60*1000),
+			ConnMaxIdleTime:  r.millis("DB_CONN_MAX_IDLE_TIME_MS", 5*60*1000),
+			ConnectRetries:   r.int("DB_CONNECT_RETRIES", 10),
+			ConnectBackoff:   r.millis("DB_CONNECT_BACKOFF_MS", 500),
+		},
+		SMTP: SMTPConfig{
+			Host:     r.get("SMTP_HOST", ""),
+			Port:     r.get("SMTP_PORT", "587"),
+			User:     r.get("SMTP_USER", ""),
+			Password: r.get("SMTP_PW", ""),
+			From:     r.get("MAIL_FROM", ""),
+		},
+		RateLimitStore:  r.get("RATE_LIMIT_STORE", "memory"),
+		LogLevel:        strings.ToLower(r.get("LOG_LEVEL", "info")),
+		MetricsAddr:     r.get("METRICS_ADDR", ""),
+		MetricsToken:    r.get("METRICS_TOKEN", ""),
+		TrustedProxies:  r.cidrs("TRUSTED_PROXIES"),
+		ShutdownTimeout: r.millis("SHUTDOWN_TIMEOUT_MS", 15000),
+		DrainDelay:      r.millis("SHUTDOWN_DRAIN_DELAY_MS", 5000),
+		RequestTimeout:  r.millis("REQUEST_TIMEOUT_MS", 10000),
+		RouteTimeouts:   r.routeTimeouts("ROUTE_TIMEOUTS"),
+	}
+	if err := errors.Join(append(r.errs, cfg.validate()...)...); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// 値の形式を確認する（全ての問題をまとめて返す）
+func (cfg *Config) validate() []error {
+	var errs []error
+	required := map[string]string{
+		"SECRET": cfg.Secret, "POSTGRES_USER": cfg.DB.User, "POSTGRES_HOST": cfg.DB.Host, "POSTGRES_DB": cfg.DB.Name,
+	}
+	for _, key := range []string{"SECRET", "POSTGRES_USER", "POSTGRES_HOST", "POSTGRES_DB"} {
+		if required[key] == "" {
+			errs = append(errs, fmt.Errorf("%s is required", key))
+		}
+	}
+	if cfg.Secret != "" && len(cfg.Secret) < minSecretLength {
+		errs = append(errs, fmt.Errorf("SECRET must be at least %d bytes", minSecretLength))
+	}
+	for key, port := range map[string]string{"PORT": cfg.Port, "POSTGRES_PORT": cfg.DB.Port} {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			errs = append(errs, fmt.Errorf("%s must be a port number: %q", key, port))
+		}
+	}
+	switch cfg.DB.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("POSTGRES_SSLMODE is not a valid sslmode: %q", cfg.DB.SSLMode))
+	}
+	if cfg.DB.MaxOpenConns > 0 && cfg.DB.MaxIdleConns > cfg.DB.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS"))
+	}
+	if cfg.RateLimitStore != "memory" && cfg.RateLimitStore != "postgres" {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE must be memory or postgres: %q", cfg.RateLimitStore))
+	}
+	switch cfg.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error: %q", cfg.LogLevel))
+	}
+	if cfg.DrainDelay > 0 && cfg.DrainDelay >= cfg.ShutdownTimeout {
+		errs = append(errs, errors.New("SHUTDOWN_DRAIN_DELAY_MS must be less than SHUTDOWN_TIMEOUT_MS"))
+	}
+	if cfg.SMTP.Host != "" && cfg.SMTP.From == "" {
+		errs = append(errs, errors.New("MAIL_FROM is required when SMTP_HOST is set"))
+	}
+	return errs
+}
+
+// 設定ファイルを読み込む（環境変数の方が優先される）
+func readFiles() (map[string]string, error) {
+	values := map[string]string{}
+	var paths []string
+	if os.Getenv("GO_ENV") == "dev" {
+		paths = append(paths, ".env") // ローカルで実行する用
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		m, err := godotenv.Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		for k, v := range m {
+			values[k] = v // 後に読んだファイルを優先する
+		}
+	}
+	return values, nil
+}
+
+type reader struct {
+	files map[string]string
+	errs  []error
+}
+
+// 環境変数 → XXX_FILE → 設定ファイル → デフォルト値 の順に探す
+func (r *reader) get(key string, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	if path := r.lookup(key + "_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("failed to read %s_FILE: %w", key, err))
+			return ""
+		}
+		return strings.TrimSpace(string(b))
+	}
+	if v := r.files[key]; v != "" {
+		return v
+	}
+	return def
+}
+
+func (r *reader) lookup(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return r.files[key]
+}
+
+// 0以上の整数
+func (r *reader) int(key string, def int) int {
+	v := r.get(key, strconv.Itoa(def))
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a non-negative integer: %q", key, v))
+		return 0
+	}
+	return n
+}
+
+// a=1&b=2 の形式
+func (r *reader) query(key string) url.Values {
+	v := r.get(key, "")
+	values, err := url.ParseQuery(v)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s must be in key=value&key=value form: %w", key, err))
+		return url.Values{}
+	}
+	return values
+}
+
+// "GET /quests=5s,POST /admin/tags/aliases=30s" の形式（パスはルート定義と同じ /quests/:questId など）
+func (r *reader) routeTimeouts(key string) map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+	v := r.get(key, "")
+	if v == "" {
+		return timeouts
+	}
+	for _, entry := range strings.Split(v, ",") {
+		route, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || !hasPath || err != nil || d < 0 {
+			r.errs = append(r.errs, fmt.Errorf("%s must be in \"METHOD /path=duration\" form: %q", key, entry))
+			continue
+		}
+		timeouts[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = d
+	}
+	return timeouts
+}
+
+// "10.0.0.0/8,192.168.1.10" の形式（CIDRかIPアドレスをカンマ区切りで指定する）
+func (r *reader) cidrs(key string) []*net.IPNet {
+	var nets []*net.IPNet
+	v := r.get(key, "")
+	if v == "" {
+		return nets
+	}
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s must be a comma-separated list of IP addresses or CIDRs: %q", key, entry))
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// ミリ秒で指定された値をtime.Durationに変換する
+func (r *reader) millis(key string, def int) time.Duration {
+	v := r.get(key, strconv.Itoa(def))
+	ms, err := strconv.Atoi(v)
+	if err != nil || ms < 0 {
+		r.errs = append(r.errs, fmt.Errorf("%s must be a non-negative integer: %q", key, v))
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
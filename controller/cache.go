@@ -0,0 +1,75 @@
+package controller
+
+/* 条件付きGET（ETag / Last-Modified）の処理 */
+
+import (
+	"bulletin-board-rest-api/model"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 更新状況・ユーザーID・クエリ文字列からETagを作成する（ユーザーや絞り込みごとに内容が変わるため）
+func questETag(stamp model.QuestStamp, userId uint, query string) string {
+	src := fmt.Sprintf("%d:%s:%d:%d:%d:%d:%d", userId, query,
+		stamp.UpdatedAt.UnixNano(), stamp.QuestCount,
+		stamp.JoinedAt.UnixNano(), stamp.ParticipantCount, stamp.UserUpdatedAt.UnixNano())
+	return fmt.Sprintf(`W/"%x"`, sha1.Sum([]byte(src)))
+}
+
+// クエスト・参加者・ユーザー情報の更新日時のうち最も新しいものを返す
+func questLastModified(stamp model.QuestStamp) time.Time {
+	lastModified := stamp.UpdatedAt
+	for _, t := range []time.Time{stamp.JoinedAt, stamp.UserUpdatedAt} {
+		if t.After(lastModified) {
+			lastModified = t
+		}
+	}
+	return lastModified.UTC().Truncate(time.Second) // HTTP日付は秒単位
+}
+
+// If-None-Matchの値のどれかがETagと一致するか（弱い比較）
+func etagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+* ETagとLast-Modifiedをレスポンスヘッダーにセットする
+* クライアントのキャッシュがまだ有効ならtrueを返す（呼び出し側は304を返す）
+* withLastModifiedがfalseならETagだけを使う（削除は件数の変化でしか分からず、更新日時が進まないため）
+ */
+func notModified(c echo.Context, stamp model.QuestStamp, userId uint, withLastModified bool) bool {
+	etag := questETag(stamp, userId, c.QueryString())
+	lastModified := questLastModified(stamp)
+
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	if withLastModified {
+		header.Set(echo.HeaderLastModified, lastModified.Format(http.TimeFormat))
+	}
+	header.Add(echo.HeaderVary, echo.HeaderAuthorization)
+
+	req := c.Request()
+	// If-None-Matchがある場合はIf-Modified-Sinceより優先する
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if ims := req.Header.Get(echo.HeaderIfModifiedSince); withLastModified && ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.After(since)
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package controller
+
+/* リクエストの受け付けとレスポンスの生成 */
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ICategoryController interface {
+	GetAllCategories(c echo.Context) error
+	CreateCategory(c echo.Context) error
+	UpdateCategory(c echo.Context) error
+	DeleteCategory(c echo.Context) error
+}
+
+type categoryController struct {
+	cu usecase.ICategoryUsecase
+}
+
+func NewCategoryController(cu usecase.ICategoryUsecase) ICategoryController {
+	return &categoryController{cu}
+}
+
+func (cc *categoryController) GetAllCategories(c echo.Context) error {
+	categoriesRes, err := cc.cu.GetAllCategories(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, categoriesRes)
+}
+
+func (cc *categoryController) CreateCategory(c echo.Context) error {
+	category := model.Category{}
+	if err := c.Bind(&category); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	categoryRes, err := cc.cu.CreateCategory(c.Request().Context(), category)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, categoryRes)
+}
+
+// カテゴリを更新する（追加項目は送られたもので置き換える）
+func (cc *categoryController) UpdateCategory(c echo.Context) error {
+	categoryId, _ := strconv.Atoi(c.Param("categoryId"))
+	category := model.Category{}
+	if err := c.Bind(&category); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err := cc.cu.UpdateCategory(c.Request().Context(), category, uint(categoryId)); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (cc *categoryController) DeleteCategory(c echo.Context) error {
+	categoryId, _ := strconv.Atoi(c.Param("categoryId"))
+	if err := cc.cu.DeleteCategory(c.Request().Context(), uint(categoryId)); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
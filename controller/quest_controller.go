@@ -10,8 +10,11 @@ package controller
 import (
 	"bulletin-board-rest-api/model"
 	"bulletin-board-rest-api/usecase"
+	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -24,9 +27,20 @@ type IQuestController interface {
 	GetQuestById(c echo.Context) error
 	CreateQuest(c echo.Context) error
 	UpdateQuest(c echo.Context) error
+	PatchQuest(c echo.Context) error
 	DeleteQuest(c echo.Context) error
+	GetQuestForView(c echo.Context) error
+	GetParticipants(c echo.Context) error
 	JoinQuest(c echo.Context) error
 	CancelQuest(c echo.Context) error
+	GetJoinRequests(c echo.Context) error
+	ApproveJoinRequest(c echo.Context) error
+	RejectJoinRequest(c echo.Context) error
+	RemoveParticipant(c echo.Context) error
+	BanParticipant(c echo.Context) error
+	CreateInvite(c echo.Context) error
+	GetInvites(c echo.Context) error
+	DeleteInvite(c echo.Context) error
 }
 
 type questController struct {
@@ -40,7 +54,26 @@ func NewQuestController(qu usecase.IQuestUsecase) IQuestController {
 }
 
 func (qc *questController) GetAllQuests(c echo.Context) error {
-	questsRes, err := qc.qu.GetAllQuests() // 引数はないよ
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+
+	// 一覧に変更がなければ本文を返さずに304を返す
+	// クエストや参加者の削除では更新日時が進まないので、一覧はETagだけで判定する
+	stamp, err := qc.qu.GetQuestsStamp(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if notModified(c, stamp, uint(userId.(float64)), false) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	// タグでの絞り込み（?tags=a,b&match=all で全てのタグ、省略時はいずれかのタグ）
+	filter := model.QuestFilter{MatchAll: c.QueryParam("match") == "all"}
+	if tags := c.QueryParam("tags"); tags != "" {
+		filter.Tags = strings.Split(tags, ",")
+	}
+	questsRes, err := qc.qu.GetAllQuests(c.Request().Context(), uint(userId.(float64)), filter)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -54,7 +87,7 @@ func (qc *questController) GetUserQuests(c echo.Context) error {
 	userId := claims["user_id"]
 
 	// ユーザーIDを元にクエストを取得
-	questsRes, err := qc.qu.GetUserQuests(uint(userId.(float64)))
+	questsRes, err := qc.qu.GetUserQuests(c.Request().Context(), uint(userId.(float64)))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -68,7 +101,7 @@ func (qc *questController) GetJoinedQuests(c echo.Context) error {
 	userId := claims["user_id"]
 
 	// ユーザーIDを元にクエストを取得
-	questsRes, err := qc.qu.GetJoinedQuests(uint(userId.(float64)))
+	questsRes, err := qc.qu.GetJoinedQuests(c.Request().Context(), uint(userId.(float64)))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -81,10 +114,19 @@ func (qc *questController) GetQuestById(c echo.Context) error {
 	userId := claims["user_id"]
 	id := c.Param("questId")       // URLからクエストIDを取得！
 	questId, _ := strconv.Atoi(id) // string型 -> int型に変換
-	questRes, err := qc.qu.GetQuestById(uint(userId.(float64)), uint(questId))
+
+	// 先に募集主本人のクエストとして取得する（他人には304で存在や更新日時を知らせない）
+	questRes, err := qc.qu.GetQuestById(c.Request().Context(), uint(userId.(float64)), uint(questId))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
+	stamp, err := qc.qu.GetQuestStamp(c.Request().Context(), uint(questId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if notModified(c, stamp, uint(userId.(float64)), true) {
+		return c.NoContent(http.StatusNotModified)
+	}
 	return c.JSON(http.StatusOK, questRes)
 }
 
@@ -98,7 +140,7 @@ func (qc *questController) CreateQuest(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 	quest.UserId = uint(userId.(float64))
-	err := qc.qu.CreateQuest(quest) // 返り値を無視する
+	err := qc.qu.CreateQuest(c.Request().Context(), quest) // 返り値を無視する
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -116,7 +158,32 @@ func (qc *questController) UpdateQuest(c echo.Context) error {
 	if err := c.Bind(&quest); err != nil {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-	err := qc.qu.UpdateQuest(quest, uint(userId.(float64)), uint(questId))
+	err := qc.qu.UpdateQuest(c.Request().Context(), quest, uint(userId.(float64)), uint(questId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+/*
+* JSON Merge Patch（application/merge-patch+json）で送られた項目だけを更新する
+* nullを送った項目はクリアされる
+ */
+func (qc *questController) PatchQuest(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("questId")
+	questId, _ := strconv.Atoi(id)
+
+	patch, err := io.ReadAll(c.Request().Body) // マージ前に解釈しないように、ボディをそのまま受け取る
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if !json.Valid(patch) {
+		return c.JSON(http.StatusBadRequest, "invalid JSON")
+	}
+	err = qc.qu.PatchQuest(c.Request().Context(), patch, uint(userId.(float64)), uint(questId))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -134,21 +201,56 @@ func (qc *questController) DeleteQuest(c echo.Context) error {
 	id := c.Param("questId")
 	questId, _ := strconv.Atoi(id)
 
-	err := qc.qu.DeleteQuest(uint(userId.(float64)), uint(questId))
+	err := qc.qu.DeleteQuest(c.Request().Context(), uint(userId.(float64)), uint(questId))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 	return c.NoContent(http.StatusNoContent)
 }
 
+// 公開・限定公開（リンクを知っている人）のクエストの詳細を取得
+func (qc *questController) GetQuestForView(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("questId")
+	questId, _ := strconv.Atoi(id)
+
+	token := c.QueryParam("token") // 招待制のクエストに参加する前に内容を確認する場合の招待トークン
+
+	questRes, err := qc.qu.GetQuestForView(c.Request().Context(), uint(userId.(float64)), uint(questId), token)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, questRes)
+}
+
+// 参加確定者の全員を取得（一覧・詳細には先頭の数人の名前しか含まれない）
+func (qc *questController) GetParticipants(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("questId")
+	questId, _ := strconv.Atoi(id)
+
+	token := c.QueryParam("token") // 招待制のクエストの招待トークン
+
+	participantsRes, err := qc.qu.GetParticipants(c.Request().Context(), uint(userId.(float64)), uint(questId), token)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, participantsRes)
+}
+
 func (qc *questController) JoinQuest(c echo.Context) error {
 	user := c.Get("user").(*jwt.Token)
 	claims := user.Claims.(jwt.MapClaims)
 	userId := claims["user_id"]
 	id := c.Param("questId")
 	questId, _ := strconv.Atoi(id)
+	token := c.QueryParam("token") // 招待制のクエストの招待トークン
 
-	err := qc.qu.JoinQuest(uint(userId.(float64)), uint(questId))
+	err := qc.qu.JoinQuest(c.Request().Context(), uint(userId.(float64)), uint(questId), token)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -162,7 +264,141 @@ func (qc *questController) CancelQuest(c echo.Context) error {
 	id := c.Param("questId")
 	questId, _ := strconv.Atoi(id)
 
-	err := qc.qu.CancelQuest(uint(userId.(float64)), uint(questId))
+	err := qc.qu.CancelQuest(c.Request().Context(), uint(userId.(float64)), uint(questId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 承認待ちの参加申請一覧を取得（募集主のみ）
+func (qc *questController) GetJoinRequests(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	id := c.Param("questId")
+	questId, _ := strconv.Atoi(id)
+
+	requestsRes, err := qc.qu.GetJoinRequests(c.Request().Context(), uint(userId.(float64)), uint(questId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, requestsRes)
+}
+
+func (qc *questController) ApproveJoinRequest(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	questId, _ := strconv.Atoi(c.Param("questId"))
+	participantId, _ := strconv.Atoi(c.Param("userId")) // 申請したユーザーのID
+
+	req := model.DecideJoinRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	err := qc.qu.ApproveJoinRequest(c.Request().Context(), uint(userId.(float64)), uint(questId), uint(participantId), req.Message)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (qc *questController) RejectJoinRequest(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	questId, _ := strconv.Atoi(c.Param("questId"))
+	participantId, _ := strconv.Atoi(c.Param("userId"))
+
+	req := model.DecideJoinRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	err := qc.qu.RejectJoinRequest(c.Request().Context(), uint(userId.(float64)), uint(questId), uint(participantId), req.Message)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 参加者をクエストから外す（募集主のみ）
+func (qc *questController) RemoveParticipant(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	questId, _ := strconv.Atoi(c.Param("questId"))
+	participantId, _ := strconv.Atoi(c.Param("userId")) // 外す参加者のID
+
+	req := model.RemoveParticipantRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	err := qc.qu.RemoveParticipant(c.Request().Context(), uint(userId.(float64)), uint(questId), uint(participantId), req.Reason)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 参加者を外し、再参加を禁止する（募集主のみ）
+func (qc *questController) BanParticipant(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	questId, _ := strconv.Atoi(c.Param("questId"))
+	participantId, _ := strconv.Atoi(c.Param("userId"))
+
+	req := model.RemoveParticipantRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	err := qc.qu.BanParticipant(c.Request().Context(), uint(userId.(float64)), uint(questId), uint(participantId), req.Reason)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 招待トークンを作成（募集主のみ）
+func (qc *questController) CreateInvite(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	questId, _ := strconv.Atoi(c.Param("questId"))
+
+	req := model.CreateInviteRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	inviteRes, err := qc.qu.CreateInvite(c.Request().Context(), uint(userId.(float64)), uint(questId), req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, inviteRes)
+}
+
+func (qc *questController) GetInvites(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	questId, _ := strconv.Atoi(c.Param("questId"))
+
+	invitesRes, err := qc.qu.GetInvites(c.Request().Context(), uint(userId.(float64)), uint(questId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, invitesRes)
+}
+
+func (qc *questController) DeleteInvite(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := claims["user_id"]
+	questId, _ := strconv.Atoi(c.Param("questId"))
+	token := c.Param("token")
+
+	err := qc.qu.DeleteInvite(c.Request().Context(), uint(userId.(float64)), uint(questId), token)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -0,0 +1,57 @@
+package controller
+
+/* リクエストの受け付けとレスポンスの生成 */
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ITagController interface {
+	AutocompleteTags(c echo.Context) error
+	GetPopularTags(c echo.Context) error
+	CreateTagAlias(c echo.Context) error
+}
+
+type tagController struct {
+	tu usecase.ITagUsecase
+}
+
+func NewTagController(tu usecase.ITagUsecase) ITagController {
+	return &tagController{tu}
+}
+
+// 入力途中のタグ名の候補を返す（?q=）
+func (tc *tagController) AutocompleteTags(c echo.Context) error {
+	tagsRes, err := tc.tu.AutocompleteTags(c.Request().Context(), c.QueryParam("q"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, tagsRes)
+}
+
+// よく使われているタグを返す（?limit=）
+func (tc *tagController) GetPopularTags(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit")) // 指定がなければusecaseの既定値
+	tagsRes, err := tc.tu.GetPopularTags(c.Request().Context(), limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, tagsRes)
+}
+
+// 表記ゆれを既存のタグにまとめる
+func (tc *tagController) CreateTagAlias(c echo.Context) error {
+	req := model.CreateTagAliasRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err := tc.tu.CreateTagAlias(c.Request().Context(), req.Alias, req.Tag); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusCreated)
+}
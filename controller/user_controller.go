@@ -3,9 +3,13 @@ package controller
 /* リクエストの受け付けとレスポンスの生成 */
 
 import (
+	"bulletin-board-rest-api/logger"
 	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
 	"bulletin-board-rest-api/usecase"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
@@ -18,6 +22,17 @@ type IUserController interface {
 	GetUserName(c echo.Context) error
 	GetUserInfo(c echo.Context) error
 	UpdateUserName(c echo.Context) error
+	GetNotifications(c echo.Context) error
+	MarkNotificationsAsRead(c echo.Context) error
+	RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc // 管理者のみ通すミドルウェア
+	UpdateProfile(c echo.Context) error
+	GetPublicProfile(c echo.Context) error
+	GetPublicProfileByUserName(c echo.Context) error
+	DeleteAccount(c echo.Context) error
+	ChangePassword(c echo.Context) error
+	ChangeEmail(c echo.Context) error
+	VerifyEmail(c echo.Context) error
+	RequireActiveSession(next echo.HandlerFunc) echo.HandlerFunc // 無効化されたJWTを拒否するミドルウェア
 }
 
 type userController struct {
@@ -35,7 +50,7 @@ func (uc *userController) SignUp(c echo.Context) error {
 	if err := c.Bind(&user); err != nil { //リクエストボディをuserにバインド（User型に変換して格納）
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-	userRes, err := uc.uu.SignUp(user) //usecaseのSignUpメソッドを呼び出し
+	userRes, err := uc.uu.SignUp(c.Request().Context(), user) //usecaseのSignUpメソッドを呼び出し
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -47,7 +62,10 @@ func (uc *userController) LogIn(c echo.Context) error {
 	if err := c.Bind(&user); err != nil {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-	jwtToken, err := uc.uu.Login(user) //usecaseのLoginメソッドを呼び出し（JWTtokenが入る）
+	jwtToken, err := uc.uu.Login(c.Request().Context(), user) //usecaseのLoginメソッドを呼び出し（JWTtokenが入る）
+	if errors.Is(err, usecase.ErrInvalidCredentials) {
+		return c.JSON(http.StatusUnauthorized, err.Error()) // アカウントロックの失敗回数はこのステータスだけで数える
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -69,7 +87,7 @@ func (uc *userController) GetUserName(c echo.Context) error {
 	userId := uint(claims["user_id"].(float64)) // float64をuintにキャスト
 
 	// ユーザーIDを元にユーザー名を取得
-	username, err := uc.uu.GetUserName(userId)
+	username, err := uc.uu.GetUserName(c.Request().Context(), userId)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -83,7 +101,7 @@ func (uc *userController) GetUserInfo(c echo.Context) error {
 	userId := uint(claims["user_id"].(float64)) // float64をuintにキャスト
 
 	// ユーザーIDを元にユーザー名を取得
-	userRes, err := uc.uu.GetUserInfo(userId)
+	userRes, err := uc.uu.GetUserInfo(c.Request().Context(), userId)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -105,9 +123,171 @@ func (uc *userController) UpdateUserName(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	err := uc.uu.UpdateUserName(userId, req.UserName)
+	err := uc.uu.UpdateUserName(c.Request().Context(), userId, req.UserName)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (uc *userController) GetNotifications(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token) // jwtをデコードした内容を取得
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	notificationsRes, err := uc.uu.GetNotifications(c.Request().Context(), userId)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, notificationsRes)
+}
+
+func (uc *userController) MarkNotificationsAsRead(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token) // jwtをデコードした内容を取得
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	if err := uc.uu.MarkNotificationsAsRead(c.Request().Context(), userId); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// JWTのユーザーが管理者でなければ403を返す（JWTのミドルウェアの後に使う）
+func (uc *userController) RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		userId := uint(claims["user_id"].(float64))
+
+		isAdmin, err := uc.uu.IsAdmin(c.Request().Context(), userId)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		if !isAdmin {
+			return c.JSON(http.StatusForbidden, "admin only")
+		}
+		return next(c)
+	}
+}
+
+func (uc *userController) UpdateProfile(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token) // jwtをデコードした内容を取得
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	req := model.UpdateProfileRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err := uc.uu.UpdateProfile(c.Request().Context(), userId, req); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// 他のユーザーの公開プロフィールを取得（メールアドレスは返さない）
+func (uc *userController) GetPublicProfile(c echo.Context) error {
+	userId, _ := strconv.Atoi(c.Param("userId"))
+	profileRes, err := uc.uu.GetPublicProfile(c.Request().Context(), uint(userId))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, profileRes)
+}
+
+func (uc *userController) GetPublicProfileByUserName(c echo.Context) error {
+	profileRes, err := uc.uu.GetPublicProfileByUserName(c.Request().Context(), c.Param("userName"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, profileRes)
+}
+
+// 退会（パスワードの再入力が必要）
+func (uc *userController) DeleteAccount(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token) // jwtをデコードした内容を取得
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	req := model.DeleteAccountRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err := uc.uu.DeleteAccount(c.Request().Context(), userId, req); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// パスワード変更（現在のパスワードが必要）。他のセッションは無効になり、新しいJWTを返す
+func (uc *userController) ChangePassword(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token) // jwtをデコードした内容を取得
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	req := model.ChangePasswordRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	jwtToken, err := uc.uu.ChangePassword(c.Request().Context(), userId, req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"token": jwtToken,
+	})
+}
+
+// メールアドレス変更（現在のパスワードが必要）。新しいアドレスの確認後に切り替わる
+func (uc *userController) ChangeEmail(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token) // jwtをデコードした内容を取得
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	req := model.ChangeEmailRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	err := uc.uu.ChangeEmail(c.Request().Context(), userId, req)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return c.JSON(http.StatusConflict, err.Error()) // 他のアカウントが使っているアドレス
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
+	return c.NoContent(http.StatusAccepted) // 確認メールの送信まで
+}
+
+// 確認メールのトークンでメールアドレスの変更を完了する（ログイン不要）
+func (uc *userController) VerifyEmail(c echo.Context) error {
+	req := model.VerifyEmailRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err := uc.uu.VerifyEmail(c.Request().Context(), req.Token); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
 	return c.NoContent(http.StatusOK)
 }
+
+// JWTのバージョンが最新でなければ（パスワード変更・退会の前に発行されたものなら）401を返す
+func (uc *userController) RequireActiveSession(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		userId := uint(claims["user_id"].(float64))
+		version, _ := claims["ver"].(float64) // verがない古いトークンは0として扱う
+
+		active, err := uc.uu.IsSessionActive(c.Request().Context(), userId, uint(version))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		if !active {
+			return c.JSON(http.StatusUnauthorized, "session has been revoked")
+		}
+		// 以降のログにユーザーIDが付くようにcontextに入れる
+		c.SetRequest(c.Request().WithContext(logger.WithUserID(c.Request().Context(), userId)))
+		return next(c)
+	}
+}
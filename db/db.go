@@ -3,39 +3,82 @@ package db
 /* データベースへの接続と切断を管理する */
 
 import (
-	"fmt"
-	"log"
+	"bulletin-board-rest-api/config"
+	"bulletin-board-rest-api/logger"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func NewDB() *gorm.DB { //*gorm.DB型のポインタを返す
-	/* ローカルで実行する用のコード */
-	if os.Getenv("GO_ENV") == "dev" { //環境変数がdevの場合
-		err := godotenv.Load() //ローカルの.envファイルを読み込む
-		if err != nil {
-			log.Fatalln(err) //エラーがあればログに出力し強制終了
+const maxConnectBackoff = 10 * time.Second
+
+func NewDB(cfg config.DBConfig) *gorm.DB { //*gorm.DB型のポインタを返す
+	// GORMのログもslogに出力する（DB_SLOW_QUERY_MSより遅いクエリは警告）
+	gormConfig := &gorm.Config{Logger: logger.NewGormLogger(slog.Default(), cfg.SlowQuery)}
+
+	// Postgresの起動を待つため、接続できるまで間隔を空けながら再試行する
+	var db *gorm.DB
+	var err error
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		db, err = gorm.Open(postgres.Open(DSN(cfg)), gormConfig) //gorm.Open()でDBに接続（pingも行う）
+		if err == nil || attempt >= cfg.ConnectRetries {
+			break
 		}
+		slog.Warn("DB connection failed, retrying", "attempt", attempt+1, "retry_in", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
 	}
-	// DBに接続するURL
-	url := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PW"), os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_PORT"), os.Getenv("POSTGRES_DB"))
-	db, err := gorm.Open(postgres.Open(url), &gorm.Config{}) //gorm.Open()でDBに接続
-	if err != nil {                                          //エラー時の処理
-		log.Fatalln(err)
+	if err != nil { //エラー時の処理（接続先が分かるようにして強制終了）
+		slog.Error("failed to connect to database", "host", cfg.Host, "port", cfg.Port, "database", cfg.Name, "error", err)
+		os.Exit(1)
 	}
-	fmt.Println("DB connected")
+
+	// コネクションプールの設定
+	sqlDB, err := db.DB()
+	if err != nil {
+		slog.Error("failed to get database handle", "error", err)
+		os.Exit(1)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	slog.Info("DB connected", "host", cfg.Host, "database", cfg.Name, "max_open_conns", cfg.MaxOpenConns)
 	return db
 }
 
-// DBをCLOSEする関数
-func CloseDB(db *gorm.DB) {
-	sqlDB, _ := db.DB() //*gorm.DB オブジェクトから実際の *sql.DB オブジェクトへのアクセスを取得
-	if err := sqlDB.Close(); err != nil {
-		log.Fatalln(err)
+// 接続文字列を作成する（パスワードなどに記号が含まれていてもエスケープされる）
+func DSN(cfg config.DBConfig) string {
+	query := url.Values{}
+	for key, values := range cfg.Options {
+		query[key] = values
+	}
+	query.Set("sslmode", cfg.SSLMode)
+	if cfg.StatementTimeout > 0 {
+		query.Set("statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)) // サーバー側でクエリを打ち切る
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, cfg.Port),
+		Path:     "/" + cfg.Name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// DBをCLOSEする関数（終了処理の途中で呼ばれるので強制終了せずエラーを返す）
+func CloseDB(db *gorm.DB) error {
+	sqlDB, err := db.DB() //*gorm.DB オブジェクトから実際の *sql.DB オブジェクトへのアクセスを取得
+	if err != nil {
+		return err
 	}
+	return sqlDB.Close()
 }
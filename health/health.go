@@ -0,0 +1,114 @@
+package health
+
+/*
+オーケストレーター向けのヘルスチェック
+ ・/healthz：プロセスが動いているか（liveness）
+ ・/readyz：リクエストを受けられるか（readiness）。DB・未適用のマイグレーション・シャットダウン中かを確認
+*/
+
+import (
+	"bulletin-board-rest-api/migration"
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type IChecker interface {
+	Liveness(c echo.Context) error
+	Readiness(c echo.Context) error
+	SetShuttingDown() // 以降のreadinessを失敗させる（ロードバランサーから外してもらう）
+}
+
+type checker struct {
+	db           *gorm.DB
+	mg           migration.IMigrator
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(db *gorm.DB, mg migration.IMigrator, timeout time.Duration) IChecker {
+	return &checker{db: db, mg: mg, timeout: timeout}
+}
+
+// 依存先ごとのチェック結果
+type checkResult struct {
+	Status    string   `json:"status"` // ok / error / pending
+	LatencyMs int64    `json:"latency_ms,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Pending   []string `json:"pending,omitempty"` // 未適用のマイグレーション
+}
+
+type readinessResponse struct {
+	Status string                 `json:"status"` // ready / not_ready
+	Checks map[string]checkResult `json:"checks"`
+}
+
+func (ch *checker) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
+
+func (ch *checker) Readiness(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), ch.timeout)
+	defer cancel()
+
+	res := readinessResponse{Status: "ready", Checks: map[string]checkResult{}}
+	if ch.shuttingDown.Load() {
+		res.Status = "not_ready"
+		res.Checks["shutdown"] = checkResult{Status: "error", Error: "shutting down"}
+	}
+
+	// DBにpingを送る
+	database := ch.checkDatabase(ctx)
+	res.Checks["database"] = database
+	if database.Status != "ok" {
+		res.Status = "not_ready"
+	} else {
+		// DBにつながる場合のみ、マイグレーションが済んでいるかを確認する
+		migrations := ch.checkMigrations(ctx)
+		res.Checks["migrations"] = migrations
+		if migrations.Status != "ok" {
+			res.Status = "not_ready"
+		}
+	}
+
+	if res.Status != "ready" {
+		return c.JSON(http.StatusServiceUnavailable, res)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (ch *checker) SetShuttingDown() {
+	ch.shuttingDown.Store(true)
+}
+
+func (ch *checker) checkDatabase(ctx context.Context) checkResult {
+	start := time.Now()
+	sqlDB, err := ch.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		return checkResult{Status: "error", Error: err.Error()}
+	}
+	return checkResult{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+}
+
+func (ch *checker) checkMigrations(ctx context.Context) checkResult {
+	pending, err := ch.mg.Pending(ctx)
+	if err != nil {
+		return checkResult{Status: "error", Error: err.Error()}
+	}
+	if len(pending) > 0 {
+		names := []string{}
+		for _, mg := range pending {
+			names = append(names, fmt.Sprintf("%04d_%s", mg.Version, mg.Name))
+		}
+		return checkResult{Status: "pending", Pending: names}
+	}
+	return checkResult{Status: "ok"}
+}
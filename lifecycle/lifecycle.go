@@ -0,0 +1,114 @@
+package lifecycle
+
+/*
+アプリケーションの起動から終了までを管理する
+ 1. Goで登録した処理（HTTPサーバーやバックグラウンドの処理）を起動する
+ 2. SIGINT/SIGTERMを受け取るか、どれかの処理がエラーで終了したら停止を始める
+ 3. OnStopで登録した停止処理を、登録と逆の順番（deferと同じ）でタイムアウト付きで実行する
+ 4. Goで起動した処理の終了を待ってから、OnCloseで登録した後始末（DBのクローズなど）を実行する
+*/
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+type App struct {
+	l               *slog.Logger
+	shutdownTimeout time.Duration
+	ctx             context.Context // 停止が始まるとキャンセルされる
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+	errOnce         sync.Once
+	err             error // 最初にエラーで終了した処理のエラー
+	hooks           []hook
+	closers         []hook // 全ての処理が終了してから実行する
+}
+
+func New(l *slog.Logger, shutdownTimeout time.Duration) *App {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &App{l: l, shutdownTimeout: shutdownTimeout, ctx: ctx, cancel: cancel}
+}
+
+// 処理を起動する。ctxがキャンセルされたら終了すること。エラーで終了するとアプリ全体を停止する
+func (a *App) Go(name string, run func(ctx context.Context) error) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		if err := run(a.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			a.l.Error("component failed", "component", name, "error", err)
+			a.errOnce.Do(func() { a.err = err })
+			a.cancel()
+		}
+	}()
+}
+
+// 停止処理を登録する（登録と逆の順番で実行される）
+func (a *App) OnStop(name string, stop func(ctx context.Context) error) {
+	a.hooks = append(a.hooks, hook{name, stop})
+}
+
+// 後始末を登録する。Goで起動した処理が全て終了してから、登録と逆の順番で実行される
+// （処理が使っているDBなどを、処理より先に閉じないようにするため）
+func (a *App) OnClose(name string, close func(ctx context.Context) error) {
+	a.closers = append(a.closers, hook{name, close})
+}
+
+// シグナルを受け取るか処理がエラーになるまで待ち、停止処理を実行する。最初のエラーを返す
+func (a *App) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case sig := <-signals:
+		a.l.Info("shutdown started", "signal", sig.String())
+	case <-a.ctx.Done():
+		a.l.Info("shutdown started", "reason", "component stopped")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	a.runHooks(ctx, a.hooks)
+
+	// バックグラウンドの処理を止め、終了を待つ（タイムアウトしたら諦める）
+	a.cancel()
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		a.l.Warn("shutdown timed out", "waiting_for", "components")
+	}
+
+	// 処理が全て終わってからDBなどを閉じる
+	a.runHooks(ctx, a.closers)
+	a.l.Info("shutdown completed")
+	return a.err
+}
+
+// 登録と逆の順番で実行する
+func (a *App) runHooks(ctx context.Context, hooks []hook) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if err := h.stop(ctx); err != nil {
+			a.l.Error("stop failed", "component", h.name, "error", err)
+			a.errOnce.Do(func() { a.err = err })
+		}
+	}
+}
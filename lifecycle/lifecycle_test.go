@@ -0,0 +1,53 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 停止処理 → 処理の終了 → 後始末 の順に実行される
+func TestRun_Order(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	app := New(l, time.Second)
+	errFail := errors.New("fail")
+
+	var mu sync.Mutex
+	events := []string{}
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	app.OnClose("db", func(ctx context.Context) error {
+		record("close db")
+		return nil
+	})
+	app.OnStop("http", func(ctx context.Context) error {
+		record("stop http")
+		return nil
+	})
+	app.Go("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond) // 終了までに時間がかかる処理
+		record("worker done")
+		return ctx.Err()
+	})
+	app.Go("failing", func(ctx context.Context) error {
+		return errFail // エラーで終了してアプリ全体の停止を始める
+	})
+
+	if err := app.Run(); !errors.Is(err, errFail) {
+		t.Errorf("Run() error = %v, want %v", err, errFail)
+	}
+	want := []string{"stop http", "worker done", "close db"}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,65 @@
+package logger
+
+/* GORMのログを同じslogのロガーに出力する */
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type gormLogger struct {
+	l             *slog.Logger
+	slowThreshold time.Duration // これより遅いクエリは警告として出力する
+	level         gormlogger.LogLevel
+}
+
+func NewGormLogger(l *slog.Logger, slowThreshold time.Duration) gormlogger.Interface {
+	return &gormLogger{l, slowThreshold, gormlogger.Warn}
+}
+
+func (gl *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &gormLogger{gl.l, gl.slowThreshold, level}
+}
+
+func (gl *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if gl.level >= gormlogger.Info {
+		gl.l.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (gl *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if gl.level >= gormlogger.Warn {
+		gl.l.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (gl *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if gl.level >= gormlogger.Error {
+		gl.l.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// クエリごとに呼ばれる（ctxはdb.WithContextで渡されたもの）
+func (gl *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if gl.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && gl.level >= gormlogger.Error:
+		sql, rows := fc()
+		gl.l.ErrorContext(ctx, "query failed", "sql", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds(), "error", err)
+	case gl.slowThreshold > 0 && elapsed > gl.slowThreshold && gl.level >= gormlogger.Warn:
+		sql, rows := fc()
+		gl.l.WarnContext(ctx, "slow query", "sql", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds())
+	case gl.level >= gormlogger.Info:
+		sql, rows := fc()
+		gl.l.DebugContext(ctx, "query", "sql", sql, "rows", rows, "elapsed_ms", elapsed.Milliseconds())
+	}
+}
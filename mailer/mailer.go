@@ -0,0 +1,45 @@
+package mailer
+
+/* メールの送信（SMTPの設定がなければログに出力するだけ） */
+
+import (
+	"bulletin-board-rest-api/config"
+	"fmt"
+	"log"
+	"net/smtp"
+)
+
+type IMailer interface {
+	Send(to string, subject string, body string) error
+}
+
+type logMailer struct{}
+
+type smtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// SMTP_HOSTが設定されていればSMTPで送信し、なければログに出力する（ローカル開発用）
+func NewMailer(cfg config.SMTPConfig) IMailer {
+	if cfg.Host == "" {
+		return &logMailer{}
+	}
+	return &smtpMailer{
+		addr: fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
+		auth: smtp.PlainAuth("", cfg.User, cfg.Password, cfg.Host),
+		from: cfg.From,
+	}
+}
+
+func (lm *logMailer) Send(to string, subject string, body string) error {
+	log.Printf("mail to=%s subject=%s\n%s", to, subject, body)
+	return nil
+}
+
+func (sm *smtpMailer) Send(to string, subject string, body string) error {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		sm.from, to, subject, body)
+	return smtp.SendMail(sm.addr, sm.auth, sm.from, []string{to}, []byte(msg))
+}
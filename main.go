@@ -1,24 +1,112 @@
 package main
 
 import (
+	"bulletin-board-rest-api/config"
 	"bulletin-board-rest-api/controller"
-	"bulletin-board-rest-api/db"
+	dbpkg "bulletin-board-rest-api/db"
+	"bulletin-board-rest-api/health"
+	"bulletin-board-rest-api/lifecycle"
+	"bulletin-board-rest-api/logger"
+	"bulletin-board-rest-api/mailer"
+	"bulletin-board-rest-api/metrics"
+	"bulletin-board-rest-api/migration"
+	"bulletin-board-rest-api/ratelimit"
 	"bulletin-board-rest-api/repository"
 	"bulletin-board-rest-api/router"
 	"bulletin-board-rest-api/usecase"
 	"bulletin-board-rest-api/validator"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
 )
 
 func main() {
-	db := db.NewDB()
+	cfg, err := config.Load()
+	if err != nil { // 設定に問題があれば起動しない
+		slog.Error("failed to load config", "error", err)
+		os.Exit(1)
+	}
+	l := logger.New(cfg.LogLevel)
+	slog.SetDefault(l) // logパッケージの出力もJSONになる
+	db := dbpkg.NewDB(cfg.DB)
+	mailer := mailer.NewMailer(cfg.SMTP)
 	userVlidator := validator.NewUserValidator()
 	questValidator := validator.NewQuestValidator()
+	categoryValidator := validator.NewCategoryValidator()
 	userRepository := repository.NewUserRepository(db)
 	questRepository := repository.NewQuestRepository(db)
-	userUsecase := usecase.NewUserUsecase(userRepository, userVlidator)
-	questUsecase := usecase.NewQuestUsecase(questRepository, userRepository, questValidator)
+	notificationRepository := repository.NewNotificationRepository(db)
+	tagRepository := repository.NewTagRepository(db)
+	categoryRepository := repository.NewCategoryRepository(db)
+	transactionManager := repository.NewTransactionManager(db) // 複数のリポジトリにまたがる処理をまとめる
+	userUsecase := usecase.NewUserUsecase(userRepository, questRepository, notificationRepository, userVlidator, mailer, cfg, transactionManager)
+	questUsecase := usecase.NewQuestUsecase(questRepository, userRepository, notificationRepository, tagRepository, categoryRepository, questValidator, transactionManager)
+	tagUsecase := usecase.NewTagUsecase(tagRepository)
+	categoryUsecase := usecase.NewCategoryUsecase(categoryRepository, categoryValidator)
 	userController := controller.NewUserController(userUsecase)
 	questController := controller.NewQuestController(questUsecase)
-	e := router.NewRouter(userController, questController)
-	e.Logger.Fatal(e.Start(":8000"))
+	tagController := controller.NewTagController(tagUsecase)
+	categoryController := controller.NewCategoryController(categoryUsecase)
+	// 複数台で動かす場合はPostgresにレート制限のカウンタを保存する
+	rateLimitStore := ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == "postgres" {
+		rateLimitStore = ratelimit.NewPostgresStore(db)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitStore, ratelimit.LockoutConfig{
+		MaxFailures: 5,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		FailWindow:  24 * time.Hour,
+	})
+	sqlDB, err := db.DB()
+	if err != nil {
+		slog.Error("failed to get database handle", "error", err)
+		os.Exit(1)
+	}
+	metrics.RegisterDB(sqlDB) // コネクションプールの状態をメトリクスに追加する
+	checker := health.NewChecker(db, migration.NewMigrator(sqlDB, l), 2*time.Second)
+	e := router.NewRouter(cfg, userController, questController, tagController, categoryController, limiter, checker, l)
+
+	//* 起動と終了の管理（停止処理は登録と逆の順番で実行される）
+	//* HTTPサーバー → メトリクスサーバー → DB の順に止める
+	app := lifecycle.New(l, cfg.ShutdownTimeout)
+	app.OnClose("db", func(ctx context.Context) error { // バックグラウンドの処理が全て終わってから閉じる
+		return dbpkg.CloseDB(db)
+	})
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsServer := &http.Server{Addr: cfg.MetricsAddr, Handler: mux}
+		app.Go("metrics", func(ctx context.Context) error {
+			return serve(metricsServer.ListenAndServe())
+		})
+		app.OnStop("metrics", metricsServer.Shutdown)
+	}
+	app.Go("http", func(ctx context.Context) error {
+		return serve(e.Start(":" + cfg.Port))
+	})
+	app.OnStop("http", func(ctx context.Context) error {
+		// 先に/readyzを503にして、ロードバランサーが振り分け先から外すまで待つ
+		// （すぐに止めると、その間に届いたリクエストが接続を拒否される）
+		checker.SetShuttingDown()
+		select {
+		case <-time.After(cfg.DrainDelay):
+		case <-ctx.Done():
+		}
+		return e.Shutdown(ctx) // 処理中のリクエストが終わるのを待つ
+	})
+	if err := app.Run(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// Shutdownで止めた場合のエラーは正常終了として扱う
+func serve(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
@@ -0,0 +1,100 @@
+package metrics
+
+/* Prometheusのメトリクスの定義と /metrics の出力 */
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// このアプリのメトリクスだけを登録するレジストリ
+var registry = prometheus.NewRegistry()
+
+// * HTTPのメトリクス（routeはエンドポイントのテンプレート。例: /quests/:questId）
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by route template and status.",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// * アプリケーション固有のメトリクス
+var (
+	QuestsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "quests_created_total",
+		Help: "Number of quests created.",
+	})
+	QuestJoins = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "quest_joins_total",
+		Help: "Number of successful quest joins and join requests.",
+	})
+	QuestCancels = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "quest_cancels_total",
+		Help: "Number of quest participations cancelled by the participant.",
+	})
+	QuestFullRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "quest_full_rejections_total",
+		Help: "Number of joins or approvals rejected because the quest was full.",
+	})
+	LoginFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "login_failures_total",
+		Help: "Number of failed login attempts.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		QuestsCreated, QuestJoins, QuestCancels, QuestFullRejections, LoginFailures,
+	)
+}
+
+// sql.DB.Stats()のコネクションプールの状態をメトリクスに追加する
+func RegisterDB(db *sql.DB) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// /metrics の出力
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// リクエスト数と処理時間を記録するミドルウェア
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			status := c.Response().Status
+			if he, ok := err.(*echo.HTTPError); ok {
+				status = he.Code // エラーレスポンスがまだ書き込まれていない場合
+			}
+			route := c.Path()
+			if route == "" {
+				route = "unmatched" // 存在しないパスでラベルが増えないようにまとめる
+			}
+			labels := prometheus.Labels{
+				"method": c.Request().Method,
+				"route":  route,
+				"status": strconv.Itoa(status),
+			}
+			httpRequests.With(labels).Inc()
+			httpDuration.With(labels).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
@@ -1,16 +1,159 @@
 package main
 
-/* データベースのマイグレーションを実行 */
+/*
+データベースのマイグレーションを実行
+ go run ./migrate up          未適用のマイグレーションを全て適用
+ go run ./migrate down N      新しい方からN個を戻す
+ go run ./migrate status      適用状況を表示
+ go run ./migrate create NAME 空のマイグレーションファイルを作成
+ go run ./migrate force V     SQLを実行せずにVまで適用済みとして記録（既存のDBをベースラインに合わせる時など）
+*/
 
 import (
+	"bulletin-board-rest-api/config"
 	"bulletin-board-rest-api/db"
-	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/logger"
+	"bulletin-board-rest-api/migration"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 )
 
+const usage = `usage: migrate [-dir DIR] <command> [args]
+
+commands:
+  up           apply all pending migrations
+  down N       roll back the latest N migrations
+  status       show applied and pending migrations
+  create NAME  create empty up/down files in DIR
+  force V      record migrations up to V as applied without running them
+`
+
 func main() {
-	dbConn := db.NewDB() //DB型のオブジェクトのアドレスを取得
-	defer fmt.Println("Successfully Migrated")
+	dir := flag.String("dir", "migration/sql", "directory for new migration files (create only)")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// createはDBに接続しない
+	if args[0] == "create" {
+		if len(args) != 2 {
+			exit(errors.New("usage: migrate create NAME"))
+		}
+		paths, err := migration.Create(*dir, args[1])
+		if err != nil {
+			exit(err)
+		}
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return
+	}
+
+	switch args[0] {
+	case "up", "down", "status", "force":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", args[0])
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		exit(err)
+	}
+	cfg.DB.StatementTimeout = 0 // 大きなテーブルの変更が途中で打ち切られないようにする
+	l := logger.New(cfg.LogLevel)
+	slog.SetDefault(l)
+	dbConn := db.NewDB(cfg.DB) //DB型のオブジェクトのアドレスを取得
 	defer db.CloseDB(dbConn)
-	dbConn.AutoMigrate(&model.User{}, &model.Quest{}, &model.QuestParticipant{}) //DBに反映させたいモデル構造のアドレスを取得して渡す
+	sqlDB, err := dbConn.DB()
+	if err != nil {
+		exit(err)
+	}
+	m := migration.NewMigrator(sqlDB, l)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := runCommand(ctx, m, args); err != nil {
+		db.CloseDB(dbConn) // os.Exitではdeferが実行されないので先に閉じる
+		exit(err)
+	}
+}
+
+func runCommand(ctx context.Context, m migration.IMigrator, args []string) error {
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mg := range applied {
+			fmt.Printf("applied %04d_%s\n", mg.Version, mg.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		} else {
+			fmt.Println("Successfully Migrated") // 全て成功した場合のみ表示する
+		}
+		return nil
+	case "down":
+		if len(args) != 2 {
+			return errors.New("usage: migrate down N")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid number: %s", args[1])
+		}
+		reverted, err := m.Down(ctx, n)
+		for _, mg := range reverted {
+			fmt.Printf("reverted %04d_%s\n", mg.Version, mg.Name)
+		}
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Missing {
+				state += " (file missing)"
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	case "force":
+		if len(args) != 2 {
+			return errors.New("usage: migrate force V")
+		}
+		v, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		if err := m.Force(ctx, uint(v)); err != nil {
+			return err
+		}
+		fmt.Printf("forced version %04d\n", v)
+		return nil
+	}
+	return fmt.Errorf("unknown command: %s", args[0])
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "migrate:", err)
+	os.Exit(1)
 }
@@ -0,0 +1,388 @@
+package migration
+
+/*
+バージョン付きのSQLマイグレーション
+ ・sql/ に NNNN_名前.up.sql と NNNN_名前.down.sql を置く（バイナリに埋め込まれる）
+ ・適用済みのバージョンは schema_migrations テーブルに記録する
+ ・1つのマイグレーションとその記録は同じトランザクションで実行する
+ ・アドバイザリーロックで、複数のプロセスが同時にマイグレーションしないようにする
+*/
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// pg_advisory_lockのキー（このアプリのマイグレーション専用）
+const lockKey int64 = 7_240_318_550_112
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version uint
+	Name    string
+	up      string
+	down    string
+}
+
+// statusコマンドで表示する1行分
+type Status struct {
+	Version   uint
+	Name      string
+	AppliedAt *time.Time // nilなら未適用
+	Missing   bool       // 適用済みだがファイルが見つからない
+}
+
+type IMigrator interface {
+	Up(ctx context.Context) ([]Migration, error)          // 未適用のものを全て適用する
+	Down(ctx context.Context, n int) ([]Migration, error) // 新しい方からn個を戻す
+	Status(ctx context.Context) ([]Status, error)         // 各マイグレーションの適用状況
+	Force(ctx context.Context, version uint) error        // SQLを実行せずに、versionまで適用済みとして記録する
+	Pending(ctx context.Context) ([]Migration, error)     // 未適用のもの（ロックを取らない。readinessの確認用）
+}
+
+type migrator struct {
+	db *sql.DB
+	l  *slog.Logger
+}
+
+func NewMigrator(db *sql.DB, l *slog.Logger) IMigrator {
+	return &migrator{db, l}
+}
+
+func (m *migrator) Up(ctx context.Context) ([]Migration, error) {
+	migrations, err := load()
+	if err != nil {
+		return nil, err
+	}
+	applied := []Migration{}
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		// AutoMigrateで作ったDBにベースラインを適用しようとすると失敗するので、先に案内する
+		if len(versions) == 0 {
+			var exists bool
+			if err := conn.QueryRowContext(ctx, `SELECT to_regclass('users') IS NOT NULL`).Scan(&exists); err != nil {
+				return err
+			}
+			if exists {
+				return errors.New("database already has tables but no migration history; run `migrate force 1` to mark the baseline as applied")
+			}
+		}
+		for _, mg := range migrations {
+			if _, ok := versions[mg.Version]; ok {
+				continue
+			}
+			m.l.Info("applying migration", "version", mg.Version, "name", mg.Name)
+			if err := run(ctx, conn, mg.up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mg.Version, mg.Name); err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", mg.Version, mg.Name, err)
+			}
+			applied = append(applied, mg)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+func (m *migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	if n < 1 {
+		return nil, errors.New("number of migrations to roll back must be at least 1")
+	}
+	migrations, err := load()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[uint]Migration{}
+	for _, mg := range migrations {
+		byVersion[mg.Version] = mg
+	}
+	reverted := []Migration{}
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		sorted := make([]uint, 0, len(versions))
+		for v := range versions {
+			sorted = append(sorted, v)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+		for i := 0; i < n && i < len(sorted); i++ {
+			mg, ok := byVersion[sorted[i]]
+			if !ok {
+				return fmt.Errorf("migration %04d is applied but its file is missing", sorted[i])
+			}
+			m.l.Info("reverting migration", "version", mg.Version, "name", mg.Name)
+			if err := run(ctx, conn, mg.down, `DELETE FROM schema_migrations WHERE version = $1`, mg.Version); err != nil {
+				return fmt.Errorf("rollback of %04d_%s failed: %w", mg.Version, mg.Name, err)
+			}
+			reverted = append(reverted, mg)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+func (m *migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedAt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := []Status{}
+	for _, mg := range migrations {
+		s := Status{Version: mg.Version, Name: mg.Name}
+		if at, ok := applied[mg.Version]; ok {
+			s.AppliedAt = &at.time
+			delete(applied, mg.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for v, at := range applied {
+		statuses = append(statuses, Status{Version: v, Name: at.name, AppliedAt: &at.time, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+func (m *migrator) Force(ctx context.Context, version uint) error {
+	migrations, err := load()
+	if err != nil {
+		return err
+	}
+	if version != 0 {
+		found := false
+		for _, mg := range migrations {
+			found = found || mg.Version == version
+		}
+		if !found {
+			return fmt.Errorf("migration %04d does not exist", version)
+		}
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+			return err
+		}
+		for _, mg := range migrations {
+			if mg.Version > version {
+				break
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mg.Version, mg.Name); err != nil {
+				return err
+			}
+		}
+		m.l.Warn("migration version forced", "version", version)
+		return tx.Commit()
+	})
+}
+
+func (m *migrator) Pending(ctx context.Context) ([]Migration, error) {
+	migrations, err := load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedAt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := []Migration{}
+	for _, mg := range migrations {
+		if _, ok := applied[mg.Version]; !ok {
+			pending = append(pending, mg)
+		}
+	}
+	return pending, nil
+}
+
+// 新しいマイグレーションの空ファイルを作成する（次の番号を自動で振る）
+func Create(dir string, name string) ([]string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return nil, errors.New("migration name is required")
+	}
+	migrations, err := parse(os.DirFS(dir), ".")
+	if err != nil {
+		return nil, err
+	}
+	next := uint(1)
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+	paths := []string{}
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", next, name, direction))
+		if err := os.WriteFile(path, []byte(""), 0o644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// 埋め込んだマイグレーションをバージョン順に読み込む
+func load() ([]Migration, error) {
+	return parse(files, "sql")
+}
+
+func parse(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		body, err := fs.ReadFile(fsys, filepath.ToSlash(filepath.Join(dir, entry.Name())))
+		if err != nil {
+			return nil, err
+		}
+		mg, ok := byVersion[uint(version)]
+		if !ok {
+			mg = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = mg
+		} else if mg.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two different names: %s and %s", version, mg.Name, match[2])
+		}
+		if match[3] == "up" {
+			mg.up = string(body)
+		} else {
+			mg.down = string(body)
+		}
+	}
+	migrations := []Migration{}
+	for _, mg := range byVersion {
+		migrations = append(migrations, *mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// アドバイザリーロックはセッション単位なので、1つのコネクションを取り出してその上で実行する
+func (m *migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, lockKey).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		m.l.Info("waiting for another migration to finish")
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+			return err
+		}
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// マイグレーションのSQLと、schema_migrationsの更新を同じトランザクションで実行する
+func run(ctx context.Context, conn *sql.Conn, body string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if strings.TrimSpace(body) != "" {
+		if _, err := tx.ExecContext(ctx, body); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[uint]struct{}, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := map[uint]struct{}{}
+	for rows.Next() {
+		var v uint
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions[v] = struct{}{}
+	}
+	return versions, rows.Err()
+}
+
+type appliedRow struct {
+	name string
+	time time.Time
+}
+
+// 適用済みのバージョンと適用日時（schema_migrationsがなければ空）
+func (m *migrator) appliedAt(ctx context.Context) (map[uint]appliedRow, error) {
+	applied := map[uint]appliedRow{}
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return applied, nil
+	}
+	rows, err := m.db.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v uint
+		var row appliedRow
+		if err := rows.Scan(&v, &row.name, &row.time); err != nil {
+			return nil, err
+		}
+		applied[v] = row
+	}
+	return applied, rows.Err()
+}
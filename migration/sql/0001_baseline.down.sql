@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS rate_limits;
+DROP TABLE IF EXISTS quest_tags;
+DROP TABLE IF EXISTS tag_aliases;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS quest_invites;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS quest_participants;
+DROP TABLE IF EXISTS quests;
+DROP TABLE IF EXISTS category_fields;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
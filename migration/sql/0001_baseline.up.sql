@@ -0,0 +1,122 @@
+-- AutoMigrateで作成していたスキーマ（既存のDBでは `migrate force 1` で適用済みにする）
+
+CREATE TABLE users (
+    id                     bigserial PRIMARY KEY,
+    email                  text UNIQUE,
+    password               text,
+    user_name              text UNIQUE,
+    is_admin               boolean NOT NULL DEFAULT false,
+    display_name           text,
+    bio                    text,
+    faculty                text,
+    grade                  bigint,
+    avatar                 bytea,
+    links                  jsonb,
+    token_version          bigint NOT NULL DEFAULT 0,
+    pending_email          text,
+    email_token            text,
+    email_token_expires_at timestamptz,
+    created_at             timestamptz,
+    updated_at             timestamptz
+);
+
+CREATE TABLE categories (
+    id         bigserial PRIMARY KEY,
+    name       text NOT NULL UNIQUE,
+    icon       text,
+    color      text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE category_fields (
+    id          bigserial PRIMARY KEY,
+    key         text NOT NULL,
+    label       text,
+    type        text NOT NULL,
+    required    boolean,
+    category_id bigint NOT NULL,
+    CONSTRAINT fk_categories_fields FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE CASCADE
+);
+
+CREATE TABLE quests (
+    id                bigserial PRIMARY KEY,
+    title             text,
+    description       text,
+    category          text,
+    category_id       bigint,
+    custom_fields     jsonb,
+    max_participants  bigint,
+    deadline          timestamptz,
+    start_time        timestamptz,
+    end_time          timestamptz,
+    image             bytea,
+    url               text,
+    requires_approval boolean,
+    visibility        text NOT NULL DEFAULT 'public',
+    created_at        timestamptz,
+    updated_at        timestamptz,
+    user_id           bigint NOT NULL,
+    CONSTRAINT fk_quests_category_ref FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL,
+    CONSTRAINT fk_quests_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE quest_participants (
+    id        bigserial PRIMARY KEY,
+    joined_at timestamptz,
+    user_id   bigint NOT NULL,
+    quest_id  bigint NOT NULL,
+    status    text NOT NULL DEFAULT 'approved',
+    message   text,
+    CONSTRAINT fk_quest_participants_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_quests_participants FOREIGN KEY (quest_id) REFERENCES quests (id)
+);
+
+CREATE TABLE notifications (
+    id         bigserial PRIMARY KEY,
+    message    text,
+    read       boolean NOT NULL DEFAULT false,
+    created_at timestamptz,
+    user_id    bigint NOT NULL,
+    CONSTRAINT fk_notifications_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE quest_invites (
+    id         bigserial PRIMARY KEY,
+    token      text NOT NULL UNIQUE,
+    expires_at timestamptz,
+    max_uses   bigint,
+    uses       bigint NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    quest_id   bigint NOT NULL,
+    CONSTRAINT fk_quest_invites_quest FOREIGN KEY (quest_id) REFERENCES quests (id) ON DELETE CASCADE
+);
+
+CREATE TABLE tags (
+    id         bigserial PRIMARY KEY,
+    name       text NOT NULL,
+    slug       text NOT NULL UNIQUE,
+    created_at timestamptz
+);
+
+CREATE TABLE tag_aliases (
+    id     bigserial PRIMARY KEY,
+    slug   text NOT NULL UNIQUE,
+    tag_id bigint NOT NULL,
+    CONSTRAINT fk_tag_aliases_tag FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+CREATE TABLE quest_tags (
+    quest_id bigint NOT NULL,
+    tag_id   bigint NOT NULL,
+    PRIMARY KEY (quest_id, tag_id),
+    CONSTRAINT fk_quest_tags_quest FOREIGN KEY (quest_id) REFERENCES quests (id) ON DELETE CASCADE,
+    CONSTRAINT fk_quest_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+CREATE TABLE rate_limits (
+    bucket   text PRIMARY KEY,
+    count    bigint NOT NULL,
+    reset_at timestamptz NOT NULL
+);
+CREATE INDEX idx_rate_limits_reset_at ON rate_limits (reset_at);
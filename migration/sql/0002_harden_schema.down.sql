@@ -0,0 +1,18 @@
+ALTER TABLE quest_invites DROP CONSTRAINT IF EXISTS chk_quest_invites_uses;
+ALTER TABLE quests DROP CONSTRAINT IF EXISTS chk_quests_time_order;
+ALTER TABLE quests DROP CONSTRAINT IF EXISTS chk_quests_visibility;
+ALTER TABLE quest_participants DROP CONSTRAINT IF EXISTS chk_quest_participants_status;
+
+DROP INDEX IF EXISTS idx_quest_participants_user_id;
+DROP INDEX IF EXISTS idx_quests_created_at;
+DROP INDEX IF EXISTS idx_quests_start_time;
+DROP INDEX IF EXISTS idx_quests_user_id;
+
+ALTER TABLE quest_participants
+    DROP CONSTRAINT IF EXISTS fk_quest_participants_quest,
+    DROP CONSTRAINT IF EXISTS fk_quest_participants_user;
+ALTER TABLE quest_participants
+    ADD CONSTRAINT fk_quests_participants FOREIGN KEY (quest_id) REFERENCES quests (id),
+    ADD CONSTRAINT fk_quest_participants_user FOREIGN KEY (user_id) REFERENCES users (id);
+
+ALTER TABLE quest_participants DROP CONSTRAINT IF EXISTS uq_quest_participants_quest_user;
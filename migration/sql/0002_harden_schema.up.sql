@@ -0,0 +1,46 @@
+-- 参加記録の重複を、参加禁止 → 参加確定 → 承認待ち → 却下 の優先順位で1件に整理する
+DELETE FROM quest_participants
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (
+            PARTITION BY user_id, quest_id
+            ORDER BY CASE status WHEN 'banned' THEN 0 WHEN 'approved' THEN 1 WHEN 'pending' THEN 2 ELSE 3 END, id
+        ) AS rn
+        FROM quest_participants
+    ) ranked
+    WHERE rn > 1
+);
+
+-- 1人のユーザーは1つのクエストに1件だけ参加記録を持つ
+ALTER TABLE quest_participants
+    ADD CONSTRAINT uq_quest_participants_quest_user UNIQUE (quest_id, user_id);
+
+-- クエスト・ユーザーが削除されたら参加記録も削除する（AutoMigrateで作った場合の制約名も考慮）
+ALTER TABLE quest_participants
+    DROP CONSTRAINT IF EXISTS fk_quests_participants,
+    DROP CONSTRAINT IF EXISTS fk_quest_participants_quest,
+    DROP CONSTRAINT IF EXISTS fk_quest_participants_user;
+ALTER TABLE quest_participants
+    ADD CONSTRAINT fk_quest_participants_quest FOREIGN KEY (quest_id) REFERENCES quests (id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_quest_participants_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX idx_quests_user_id ON quests (user_id);
+CREATE INDEX idx_quests_start_time ON quests (start_time);
+CREATE INDEX idx_quests_created_at ON quests (created_at);
+CREATE INDEX idx_quest_participants_user_id ON quest_participants (user_id);
+
+-- 状態・公開範囲は決められた値のみ
+ALTER TABLE quest_participants
+    ADD CONSTRAINT chk_quest_participants_status CHECK (status IN ('approved', 'pending', 'rejected', 'banned'));
+ALTER TABLE quests
+    ADD CONSTRAINT chk_quests_visibility CHECK (visibility IN ('public', 'unlisted', 'invite_only'));
+
+-- 時刻の前後関係（ゼロ値 = 未設定 の場合は確認しない）
+-- 既存のデータには適用せず、今後の作成・更新のみ確認する
+ALTER TABLE quests
+    ADD CONSTRAINT chk_quests_time_order CHECK (
+        end_time IS NULL OR end_time <= '0001-01-01 00:00:00+00'
+        OR ((start_time IS NULL OR start_time <= end_time) AND (deadline IS NULL OR deadline <= end_time))
+    ) NOT VALID;
+ALTER TABLE quest_invites
+    ADD CONSTRAINT chk_quest_invites_uses CHECK (max_uses = 0 OR uses <= max_uses) NOT VALID;
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN suspended boolean NOT NULL DEFAULT false;
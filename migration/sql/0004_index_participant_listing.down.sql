@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_quest_participants_quest_status_joined;
@@ -0,0 +1,2 @@
+-- クエスト一覧で参加確定者の人数・名前を参加順に集計するための索引
+CREATE INDEX IF NOT EXISTS idx_quest_participants_quest_status_joined ON quest_participants (quest_id, status, joined_at);
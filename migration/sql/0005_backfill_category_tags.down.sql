@@ -0,0 +1 @@
+-- 追加したタグ・タグ付けは、後から手動で付けたものと区別できないので戻さない
@@ -0,0 +1,28 @@
+-- 旧仕様の自由記述のカテゴリ（quests.category）をタグとして登録し、クエストに付ける
+-- タグで絞り込んだときに、タグ機能より前に作成されたクエストも見つかるようにする
+-- slugはアプリのnormalizeTagSlugと同じ規則（全角英数字を半角に・小文字に・空白と_と-を1つの-にまとめる）
+
+CREATE TEMPORARY TABLE category_tags ON COMMIT DROP AS
+SELECT id AS quest_id,
+       regexp_replace(category, '^[[:space:]　]+|[[:space:]　]+$', '', 'g') AS name,
+       trim(BOTH '-' FROM lower(regexp_replace(translate(category, '！＂＃＄％＆＇（）＊＋，－．／０１２３４５６７８９：；＜＝＞？＠ＡＢＣＤＥＦＧＨＩＪＫＬＭＮＯＰＱＲＳＴＵＶＷＸＹＺ［＼］＾＿｀ａｂｃｄｅｆｇｈｉｊｋｌｍｎｏｐｑｒｓｔｕｖｗｘｙｚ｛｜｝～', '!"#$%&''()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\]^_`abcdefghijklmnopqrstuvwxyz{|}~'), '[[:space:]　_-]+', '-', 'g'))) AS slug
+FROM quests
+WHERE category <> '';
+
+DELETE FROM category_tags WHERE slug = '';
+
+-- 表記ゆれとして統合済みのslugは統合先のタグを使うので作成しない
+INSERT INTO tags (name, slug, created_at)
+SELECT MIN(name), slug, now()
+FROM category_tags
+WHERE slug NOT IN (SELECT slug FROM tag_aliases)
+GROUP BY slug
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO quest_tags (quest_id, tag_id)
+SELECT c.quest_id, COALESCE(a.tag_id, t.id)
+FROM category_tags c
+LEFT JOIN tag_aliases a ON a.slug = c.slug
+LEFT JOIN tags t ON t.slug = c.slug
+WHERE COALESCE(a.tag_id, t.id) IS NOT NULL
+ON CONFLICT DO NOTHING;
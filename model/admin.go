@@ -0,0 +1,24 @@
+package model
+
+/* 管理者用のCLIで使う集計結果の定義 */
+
+// purgeで削除・初期化した件数
+type PurgeResult struct {
+	RateLimits    int64 // 期限切れのレート制限のカウンタ
+	Invites       int64 // 期限切れ・使い切った招待トークン
+	EmailTokens   int64 // 期限切れのメールアドレス確認トークン
+	Notifications int64 // 古い既読のお知らせ
+}
+
+// 利用状況の集計
+type AdminStats struct {
+	Users           int64
+	Admins          int64
+	SuspendedUsers  int64
+	Quests          int64
+	UpcomingQuests  int64 // 開始前のクエスト
+	Participations  int64 // 参加確定の件数
+	PendingRequests int64 // 承認待ちの参加申請
+	Tags            int64
+	Categories      int64
+}
@@ -0,0 +1,81 @@
+package model
+
+/* 管理者が管理するカテゴリの一覧（カテゴリごとの追加項目を含む）の定義 */
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type Category struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	Name      string          `json:"name" gorm:"unique;not null"`
+	Icon      string          `json:"icon"`  // アイコン名（フロントエンドで使うもの）
+	Color     string          `json:"color"` // #RRGGBB
+	Fields    []CategoryField `json:"fields" gorm:"foreignKey:CategoryId; constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// カテゴリごとの追加項目（例：「教室番号」「必要な持ち物」）
+type CategoryField struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	Key        string `json:"key" gorm:"not null"` // custom_fieldsのキー
+	Label      string `json:"label"`               // 表示名
+	Type       string `json:"type" gorm:"not null"`
+	Required   bool   `json:"required"`
+	CategoryId uint   `json:"category_id" gorm:"not null"`
+}
+
+// 追加項目の型
+const (
+	FieldTypeText    = "text"
+	FieldTypeNumber  = "number"
+	FieldTypeBoolean = "boolean"
+	FieldTypeDate    = "date" // 2006-01-02 形式の文字列
+)
+
+// クエストごとの追加項目の値（jsonbとして保存する）
+type CustomFields map[string]interface{}
+
+func (cf CustomFields) Value() (driver.Value, error) {
+	if cf == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(cf)
+	return string(b), err
+}
+
+func (cf *CustomFields) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*cf = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for CustomFields: %T", value)
+	}
+	return json.Unmarshal(b, cf)
+}
+
+// クライアントに返す情報
+type CategoryResponse struct {
+	ID     uint                    `json:"id"`
+	Name   string                  `json:"name"`
+	Icon   string                  `json:"icon"`
+	Color  string                  `json:"color"`
+	Fields []CategoryFieldResponse `json:"fields"`
+}
+
+type CategoryFieldResponse struct {
+	Key      string `json:"key"`
+	Label    string `json:"label"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
@@ -0,0 +1,22 @@
+package model
+
+/* ユーザーへのお知らせを管理するテーブルの定義 */
+
+import "time"
+
+type Notification struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Message   string    `json:"message"`
+	Read      bool      `json:"read" gorm:"not null;default:false"` // 既読かどうか
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"`
+	UserId    uint      `json:"user_id" gorm:"not null"`
+}
+
+// クライアントに返す情報
+type NotificationResponse struct {
+	ID        uint      `json:"id"`
+	Message   string    `json:"message"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
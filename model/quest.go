@@ -5,50 +5,120 @@ package model
 import "time"
 
 type Quest struct {
-	ID              uint               `json:"id" gorm:"primaryKey"`
-	Title           string             `json:"title"`
-	Description     string             `json:"description"`
-	Category        string             `json:"category"`
-	MaxParticipants uint               `json:"max_participants" `
-	Deadline        time.Time          `json:"deadline" `
-	StartTime       time.Time          `json:"start_time"`
-	EndTime         time.Time          `json:"end_time"`
-	Image           []byte             `json:"image"` // 画像をバイナリデータで保存
-	URL             string             `json:"url"`
-	CreatedAt       time.Time          `json:"created_at"`
-	UpdatedAt       time.Time          `json:"updated_at"`
-	User            User               `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"` // UserIDを元にUserテーブルと紐付ける
-	UserId          uint               `json:"user_id" gorm:"not null"`
-	Participants    []QuestParticipant `json:"participants" gorm:"foreignKey:QuestId"` // QuestParticipantテーブルと紐付ける
+	ID               uint               `json:"id" gorm:"primaryKey"`
+	Title            string             `json:"title"`
+	Description      string             `json:"description"`
+	Category         string             `json:"category"`    // 自由記述のカテゴリ（旧仕様）
+	CategoryId       *uint              `json:"category_id"` // 管理者が用意したカテゴリ
+	CategoryRef      *Category          `json:"-" gorm:"foreignKey:CategoryId; constraint:OnDelete:SET NULL"`
+	CustomFields     CustomFields       `json:"custom_fields" gorm:"type:jsonb"` // カテゴリごとの追加項目の値
+	MaxParticipants  uint               `json:"max_participants" `
+	Deadline         time.Time          `json:"deadline" `
+	StartTime        time.Time          `json:"start_time" gorm:"index"`
+	EndTime          time.Time          `json:"end_time"`
+	Image            []byte             `json:"image"` // 画像をバイナリデータで保存
+	URL              string             `json:"url"`
+	RequiresApproval bool               `json:"requires_approval"`                         // trueなら参加に募集主の承認が必要
+	Visibility       string             `json:"visibility" gorm:"not null;default:public"` // 公開範囲
+	CreatedAt        time.Time          `json:"created_at" gorm:"index"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+	User             User               `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"` // UserIDを元にUserテーブルと紐付ける
+	UserId           uint               `json:"user_id" gorm:"not null;index"`
+	Participants     []QuestParticipant `json:"participants" gorm:"foreignKey:QuestId; constraint:OnDelete:CASCADE"` // QuestParticipantテーブルと紐付ける
+	Tags             []Tag              `json:"-" gorm:"many2many:quest_tags;constraint:OnDelete:CASCADE"`           // 中間テーブルquest_tagsでTagテーブルと紐付ける
+	TagNames         []string           `json:"tags" gorm:"-"`                                                       // クライアントから受け取るタグ名（nilなら変更しない）
 }
 
 // クライアントに返す情報
 type QuestResponse struct {
-	ID              uint       `json:"id" gorm:"primaryKey"`
-	Title           string     `json:"title" `
-	Description     string     `json:"description"`
-	Category        string     `json:"category" `
-	MaxParticipants uint       `json:"max_participants" `
-	Deadline        *time.Time `json:"deadline" `
-	StartTime       *time.Time `json:"start_time"`
-	EndTime         *time.Time `json:"end_time"`
-	Image           []byte     `json:"image"` // 画像をバイナリデータで保存
-	URL             string     `json:"url"`
-	CreatedAt       time.Time  `json:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at"`
-	UserName        string     `json:"user_name"`    // 作成者の名前
-	Participants    []string   `json:"participants"` // 参加者の名前のリスト
+	ID               uint         `json:"id" gorm:"primaryKey"`
+	Title            string       `json:"title" `
+	Description      string       `json:"description"`
+	Category         string       `json:"category" `
+	CategoryId       *uint        `json:"category_id"`
+	CustomFields     CustomFields `json:"custom_fields"`
+	MaxParticipants  uint         `json:"max_participants" `
+	Deadline         *time.Time   `json:"deadline" `
+	StartTime        *time.Time   `json:"start_time"`
+	EndTime          *time.Time   `json:"end_time"`
+	Image            []byte       `json:"image"` // 画像をバイナリデータで保存
+	URL              string       `json:"url"`
+	RequiresApproval bool         `json:"requires_approval"`
+	Visibility       string       `json:"visibility"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+	UserName         string       `json:"user_name"`             // 作成者の名前
+	Participants     []string     `json:"participants"`          // 参加者の名前（参加確定者のうち先頭からParticipantPreviewLimit人まで）
+	ParticipantCount int64        `json:"participant_count"`     // 参加確定者の人数
+	Joined           bool         `json:"joined"`                // ログインユーザーが参加（申請）しているか
+	Tags             []string     `json:"tags"`                  // タグ名のリスト
+	JoinStatus       string       `json:"join_status,omitempty"` // ログインユーザーの参加状態（参加していなければ省略）
+}
+
+// 一覧に表示する参加者の名前の上限（全員は GET /quests/participants/:questId で取得する）
+const ParticipantPreviewLimit = 5
+
+// 一覧表示用に1回のクエリで集計したクエスト（参加者の行は全て読み込まない）
+type QuestSummary struct {
+	ID                 uint
+	Title              string
+	Description        string
+	Category           string
+	CategoryId         *uint
+	CustomFields       CustomFields
+	MaxParticipants    uint
+	Deadline           time.Time
+	StartTime          time.Time
+	EndTime            time.Time
+	URL                string
+	RequiresApproval   bool
+	Visibility         string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	UserId             uint
+	UserName           string     // 作成者の名前
+	ParticipantCount   int64      // 参加確定者の人数
+	ParticipantPreview StringList // 参加確定者の名前（参加した順に最大ParticipantPreviewLimit人）
+	JoinStatus         string     // 閲覧しているユーザーの参加状態（参加していなければ空）
+	TagNames           StringList // タグ名のリスト
 }
 
 type EditQuestResponse struct {
-	ID              uint       `json:"id" gorm:"primaryKey"`
-	Title           string     `json:"title" `
-	Description     string     `json:"description"`
-	Category        string     `json:"category" `
-	MaxParticipants uint       `json:"max_participants" `
-	Deadline        *time.Time `json:"deadline" `
-	StartTime       *time.Time `json:"start_time"`
-	EndTime         *time.Time `json:"end_time"`
-	Image           []byte     `json:"image"` // 画像をバイナリデータで保存
-	URL             string     `json:"url"`
+	ID               uint         `json:"id" gorm:"primaryKey"`
+	Title            string       `json:"title" `
+	Description      string       `json:"description"`
+	Category         string       `json:"category" `
+	CategoryId       *uint        `json:"category_id"`
+	CustomFields     CustomFields `json:"custom_fields"`
+	MaxParticipants  uint         `json:"max_participants" `
+	Deadline         *time.Time   `json:"deadline" `
+	StartTime        *time.Time   `json:"start_time"`
+	EndTime          *time.Time   `json:"end_time"`
+	Image            []byte       `json:"image"` // 画像をバイナリデータで保存
+	URL              string       `json:"url"`
+	RequiresApproval bool         `json:"requires_approval"`
+	Visibility       string       `json:"visibility"`
+	Tags             []string     `json:"tags"`
+}
+
+// クエストの公開範囲
+const (
+	VisibilityPublic     = "public"      // 一覧に表示される
+	VisibilityUnlisted   = "unlisted"    // 一覧には表示されず、リンクを知っている人だけが見られる
+	VisibilityInviteOnly = "invite_only" // 招待トークンがないと参加できない
+)
+
+// 条件付きGET（ETag / Last-Modified）の判定に使うクエストの更新状況
+type QuestStamp struct {
+	UpdatedAt        time.Time // クエストの最終更新日時
+	QuestCount       int64     // クエスト数（削除の検出用）
+	JoinedAt         time.Time // 最後に参加者が追加された日時
+	ParticipantCount int64     // 参加者数（参加取り消しの検出用）
+	UserUpdatedAt    time.Time // 募集主・参加者のユーザー情報の最終更新日時（名前やプロフィールの変更の検出用）
+}
+
+// クエスト一覧の絞り込み条件
+type QuestFilter struct {
+	Tags     []string // タグのslug
+	MatchAll bool     // trueなら全てのタグを持つクエスト（AND）、falseならいずれか（OR）
 }
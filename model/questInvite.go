@@ -0,0 +1,31 @@
+package model
+
+/* 招待制クエストの招待トークンを管理するテーブルの定義 */
+
+import "time"
+
+type QuestInvite struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Token     string    `json:"token" gorm:"unique;not null"`
+	ExpiresAt time.Time `json:"expires_at"`                     // ゼロ値なら期限なし
+	MaxUses   uint      `json:"max_uses"`                       // 0なら回数制限なし
+	Uses      uint      `json:"uses" gorm:"not null;default:0"` // 使用された回数
+	CreatedAt time.Time `json:"created_at"`
+	Quest     Quest     `json:"quest" gorm:"foreignKey:QuestId; constraint:OnDelete:CASCADE"`
+	QuestId   uint      `json:"quest_id" gorm:"not null"`
+}
+
+// クライアントに返す情報
+type QuestInviteResponse struct {
+	Token     string     `json:"token"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxUses   uint       `json:"max_uses"`
+	Uses      uint       `json:"uses"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// 招待トークン作成のリクエストを格納する構造体
+type CreateInviteRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"` // 任意
+	MaxUses   uint       `json:"max_uses"`   // 任意
+}
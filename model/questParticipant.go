@@ -6,9 +6,45 @@ import "time"
 
 type QuestParticipant struct {
 	ID       uint      `json:"id" gorm:"primaryKey"` //! このIDを指定することはない
+	JoinedAt time.Time `json:"joined_at" gorm:"index:idx_quest_participants_quest_status_joined,priority:3"`
+	User     User      `json:"user" gorm:"foreignKey:UserId; constraint:OnDelete:CASCADE"` // ユーザーが削除されたら参加記録も削除
+	UserId   uint      `json:"user_id" gorm:"not null;index;uniqueIndex:uq_quest_participants_quest_user,priority:2"`
+	Quest    Quest     `json:"quest" gorm:"foreignKey:QuestId;references:ID; constraint:OnDelete:CASCADE"` // クエストが削除されたら参加記録も削除
+	QuestId  uint      `json:"quest_id" gorm:"not null;uniqueIndex:uq_quest_participants_quest_user,priority:1;index:idx_quest_participants_quest_status_joined,priority:1"`
+	Status   string    `json:"status" gorm:"not null;default:approved;index:idx_quest_participants_quest_status_joined,priority:2"` // 参加状態（承認制のクエストではpendingから始まる）
+	Message  string    `json:"message"`                                                                                             // 承認・却下・参加禁止時の募集主からのメッセージ
+}
+
+// 参加状態
+const (
+	ParticipantApproved = "approved" // 参加確定
+	ParticipantPending  = "pending"  // 募集主の承認待ち
+	ParticipantRejected = "rejected" // 募集主が却下
+	ParticipantBanned   = "banned"   // 募集主により参加を禁止された
+)
+
+// 参加申請の一覧としてクライアントに返す情報
+type JoinRequestResponse struct {
+	UserId   uint      `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Status   string    `json:"status"`
+	Message  string    `json:"message"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// 参加確定者の一覧で返す情報
+type ParticipantResponse struct {
+	UserId   uint      `json:"user_id"`
+	UserName string    `json:"user_name"`
 	JoinedAt time.Time `json:"joined_at"`
-	User     User      `json:"user" gorm:"foreignKey:UserId;"` // ユーザーが削除されたら参加記録も削除
-	UserId   uint      `json:"user_id" gorm:"not null"`
-	Quest    Quest     `json:"quest" gorm:"foreignKey:QuestId;references:ID;"` // クエストが削除されたら参加記録も削除
-	QuestId  uint      `json:"quest_id" gorm:"not null"`
+}
+
+// 承認・却下のリクエストを格納する構造体
+type DecideJoinRequest struct {
+	Message string `json:"message"` // 任意
+}
+
+// 参加者の削除・参加禁止のリクエストを格納する構造体
+type RemoveParticipantRequest struct {
+	Reason string `json:"reason"` // 任意
 }
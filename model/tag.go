@@ -0,0 +1,33 @@
+package model
+
+/* クエストのタグを管理するテーブルの定義 */
+
+import "time"
+
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`        // 最初に登録された表示名
+	Slug      string    `json:"slug" gorm:"unique;not null"` // 正規化した名前（表記ゆれの統合に使う）
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// タグの別名（「勉強会」→「勉強」のように、別の表記を既存のタグにまとめる）
+type TagAlias struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Slug  string `json:"slug" gorm:"unique;not null"` // 正規化した別名
+	Tag   Tag    `json:"tag" gorm:"foreignKey:TagId; constraint:OnDelete:CASCADE"`
+	TagId uint   `json:"tag_id" gorm:"not null"`
+}
+
+// クライアントに返す情報
+type TagResponse struct {
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	QuestCount int64  `json:"quest_count"` // 人気のタグ一覧でのみ使用
+}
+
+// 別名登録のリクエストを格納する構造体
+type CreateTagAliasRequest struct {
+	Alias string `json:"alias"` // 別名
+	Tag   string `json:"tag"`   // まとめ先のタグ
+}
@@ -2,15 +2,32 @@ package model
 
 /* 作成するテーブルを定義するところ */
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Email     string    `json:"email" gorm:"unique"`
-	Password  string    `json:"password"`
-	UserName  string    `json:"user_name" gorm:"unique"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	Email               string     `json:"email" gorm:"unique"`
+	Password            string     `json:"password"`
+	UserName            string     `json:"user_name" gorm:"unique"`
+	IsAdmin             bool       `json:"is_admin" gorm:"not null;default:false"` // 管理者かどうか（サインアップでは設定できない）
+	Suspended           bool       `json:"-" gorm:"not null;default:false"`        // 利用停止中ならログインできない（管理者のCLIで設定）
+	DisplayName         string     `json:"display_name"`                           // ここからプロフィール
+	Bio                 string     `json:"bio"`                                    // 自己紹介
+	Faculty             string     `json:"faculty"`                                // 学部・学科
+	Grade               uint       `json:"grade"`                                  // 学年（0なら未設定）
+	Avatar              []byte     `json:"avatar"`                                 // アイコン画像をバイナリデータで保存
+	Links               StringList `json:"links" gorm:"type:jsonb"`
+	TokenVersion        uint       `json:"-" gorm:"not null;default:0"` // 変更するとそれ以前に発行したJWTが無効になる
+	PendingEmail        string     `json:"-"`                           // 確認待ちの新しいメールアドレス
+	EmailToken          string     `json:"-"`                           // メールアドレス確認用トークンのハッシュ
+	EmailTokenExpiresAt time.Time  `json:"-"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 // クライアントに返す情報
@@ -24,3 +41,84 @@ type UserResponse struct {
 type UpdateUserNameRequest struct {
 	UserName string `json:"user_name"`
 }
+
+// プロフィール編集のリクエストを格納する構造体
+type UpdateProfileRequest struct {
+	DisplayName string     `json:"display_name"`
+	Bio         string     `json:"bio"`
+	Faculty     string     `json:"faculty"`
+	Grade       uint       `json:"grade"`
+	Avatar      []byte     `json:"avatar"` // base64で送る
+	Links       StringList `json:"links"`
+}
+
+// パスワード変更のリクエストを格納する構造体
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// メールアドレス変更のリクエストを格納する構造体
+type ChangeEmailRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewEmail        string `json:"new_email"`
+}
+
+// メールアドレス確認のリクエストを格納する構造体
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// 退会のリクエストを格納する構造体
+type DeleteAccountRequest struct {
+	Password   string `json:"password"`    // 本人確認のためのパスワード
+	QuestMode  string `json:"quest_mode"`  // 作成したクエストの扱い（delete / transfer）
+	TransferTo string `json:"transfer_to"` // transferの場合の引き継ぎ先（共同主催者）のユーザー名
+}
+
+// 退会時の作成したクエストの扱い
+const (
+	QuestModeDelete   = "delete"   // 削除する
+	QuestModeTransfer = "transfer" // 共同主催者に引き継ぐ
+)
+
+// 公開プロフィールとしてクライアントに返す情報（メールアドレスは含めない）
+type PublicProfileResponse struct {
+	ID            uint            `json:"id"`
+	UserName      string          `json:"user_name"`
+	DisplayName   string          `json:"display_name"`
+	Bio           string          `json:"bio"`
+	Faculty       string          `json:"faculty"`
+	Grade         uint            `json:"grade"`
+	Avatar        []byte          `json:"avatar"`
+	Links         []string        `json:"links"`
+	CreatedQuests []QuestResponse `json:"created_quests"` // 公開クエストのみ
+	JoinedQuests  []QuestResponse `json:"joined_quests"`  // 公開クエストのみ
+}
+
+// 文字列の配列（jsonbとして保存する）
+type StringList []string
+
+func (sl StringList) Value() (driver.Value, error) {
+	if sl == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(sl)
+	return string(b), err
+}
+
+func (sl *StringList) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*sl = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for StringList: %T", value)
+	}
+	return json.Unmarshal(b, sl)
+}
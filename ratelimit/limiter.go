@@ -0,0 +1,172 @@
+package ratelimit
+
+/*
+ログイン・サインアップの総当たり攻撃対策
+ 1. IPアドレスごとのレート制限
+ 2. アカウント（メールアドレス）ごとのレート制限
+ 3. 一定回数ログインに失敗したアカウントの一時ロック（失敗が続くほどロック時間が倍になる）
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// アカウントロックの設定
+type LockoutConfig struct {
+	MaxFailures int           // この回数失敗したらロックする
+	BaseLockout time.Duration // 最初のロック時間（以降、失敗するたびに倍になる）
+	MaxLockout  time.Duration // ロック時間の上限
+	FailWindow  time.Duration // 失敗回数を覚えておく期間
+}
+
+type ILimiter interface {
+	PerIP(name string, limit int, window time.Duration) echo.MiddlewareFunc      // IPアドレスごとの制限
+	PerAccount(name string, limit int, window time.Duration) echo.MiddlewareFunc // リクエストボディのemailごとの制限
+	Lockout() echo.MiddlewareFunc                                                // ログイン失敗によるアカウントロック
+}
+
+type limiter struct {
+	store   IStore
+	lockout LockoutConfig
+}
+
+func NewLimiter(store IStore, lockout LockoutConfig) ILimiter {
+	return &limiter{store, lockout}
+}
+
+// RateLimit-* ヘッダーをセットする（IETFのRateLimitヘッダーの草案に沿った形式）
+func setRateLimitHeaders(c echo.Context, limit int, entry Entry) {
+	remaining := limit - entry.Count
+	if remaining < 0 {
+		remaining = 0
+	}
+	header := c.Response().Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(secondsUntil(entry.ResetAt)))
+}
+
+// 429を返す（Retry-Afterに再試行できるまでの秒数を入れる）
+func tooManyRequests(c echo.Context, until time.Time) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(secondsUntil(until)))
+	return c.JSON(http.StatusTooManyRequests, "too many requests")
+}
+
+func secondsUntil(t time.Time) int {
+	return int(math.Ceil(time.Until(t).Seconds()))
+}
+
+// リクエストボディからemailを取り出す（ハンドラーでも読めるようにボディを戻しておく）
+func accountKey(c echo.Context) string {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return ""
+	}
+	c.Request().Body = io.NopCloser(bytes.NewReader(body))
+	req := struct {
+		Email string `json:"email"`
+	}{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(req.Email))
+}
+
+func (l *limiter) PerIP(name string, limit int, window time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			entry, err := l.store.Incr("ip:"+name+":"+c.RealIP(), window)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, err.Error())
+			}
+			setRateLimitHeaders(c, limit, entry)
+			if entry.Count > limit {
+				return tooManyRequests(c, entry.ResetAt)
+			}
+			return next(c)
+		}
+	}
+}
+
+func (l *limiter) PerAccount(name string, limit int, window time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			email := accountKey(c)
+			if email == "" {
+				return next(c) // emailがなければバリデーションでエラーになる
+			}
+			entry, err := l.store.Incr("account:"+name+":"+email, window)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, err.Error())
+			}
+			setRateLimitHeaders(c, limit, entry)
+			if entry.Count > limit {
+				return tooManyRequests(c, entry.ResetAt)
+			}
+			return next(c)
+		}
+	}
+}
+
+func (l *limiter) Lockout() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			email := accountKey(c)
+			if email == "" {
+				return next(c)
+			}
+			// ロック中ならハンドラーを呼ばずに429を返す
+			lock, err := l.store.Get("lock:" + email)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, err.Error())
+			}
+			if lock.Count > 0 {
+				setRateLimitHeaders(c, l.lockout.MaxFailures, lock) // lock.CountはMaxFailures以上なので残りは0になる
+				return tooManyRequests(c, lock.ResetAt)
+			}
+
+			if err := next(c); err != nil {
+				return err
+			}
+
+			// ログインの結果で失敗回数を更新する
+			// 数えるのは認証情報の誤り（401）だけで、入力の不備やサーバーの障害ではロックしない
+			if c.Response().Status == http.StatusOK {
+				l.store.Delete("fail:" + email)
+				return nil
+			}
+			if c.Response().Status != http.StatusUnauthorized {
+				return nil
+			}
+			failures, err := l.store.Incr("fail:"+email, l.lockout.FailWindow)
+			if err != nil {
+				return nil // レスポンスは返し済み
+			}
+			if failures.Count >= l.lockout.MaxFailures {
+				l.store.Set("lock:"+email, failures.Count, l.lockoutDuration(failures.Count))
+			}
+			return nil
+		}
+	}
+}
+
+// MaxFailures回目でBaseLockout、以降1回失敗するごとに倍（上限MaxLockout）
+func (l *limiter) lockoutDuration(failures int) time.Duration {
+	d := l.lockout.BaseLockout
+	for i := l.lockout.MaxFailures; i < failures && d < l.lockout.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > l.lockout.MaxLockout {
+		d = l.lockout.MaxLockout
+	}
+	return d
+}
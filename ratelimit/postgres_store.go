@@ -0,0 +1,62 @@
+package ratelimit
+
+/* 複数台で動かす場合のPostgres上のストア */
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// カウンタを保存するテーブルの定義
+type RateLimit struct {
+	Bucket  string    `gorm:"primaryKey"`
+	Count   int       `gorm:"not null"`
+	ResetAt time.Time `gorm:"not null;index"`
+}
+
+type postgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) IStore {
+	return &postgresStore{db}
+}
+
+func (ps *postgresStore) Incr(key string, window time.Duration) (Entry, error) {
+	// 1回のUPSERTで「期限切れならリセット、そうでなければ+1」を行う（同時実行でも数え漏れがない）
+	now := time.Now()
+	entry := Entry{}
+	err := ps.db.Raw(`INSERT INTO rate_limits (bucket, count, reset_at) VALUES (?, 1, ?)
+		ON CONFLICT (bucket) DO UPDATE SET
+			count = CASE WHEN rate_limits.reset_at <= ? THEN 1 ELSE rate_limits.count + 1 END,
+			reset_at = CASE WHEN rate_limits.reset_at <= ? THEN EXCLUDED.reset_at ELSE rate_limits.reset_at END
+		RETURNING count, reset_at`, key, now.Add(window), now, now).Scan(&entry).Error
+	if err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+func (ps *postgresStore) Get(key string) (Entry, error) {
+	row := RateLimit{}
+	err := ps.db.Where("bucket = ? AND reset_at > ?", key, time.Now()).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Entry{}, nil
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Count: row.Count, ResetAt: row.ResetAt}, nil
+}
+
+func (ps *postgresStore) Set(key string, count int, ttl time.Duration) error {
+	return ps.db.Exec(`INSERT INTO rate_limits (bucket, count, reset_at) VALUES (?, ?, ?)
+		ON CONFLICT (bucket) DO UPDATE SET count = EXCLUDED.count, reset_at = EXCLUDED.reset_at`,
+		key, count, time.Now().Add(ttl)).Error
+}
+
+func (ps *postgresStore) Delete(key string) error {
+	return ps.db.Where("bucket = ?", key).Delete(&RateLimit{}).Error
+}
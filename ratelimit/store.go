@@ -0,0 +1,87 @@
+package ratelimit
+
+/* レート制限のカウンタを保存するストア */
+
+import (
+	"sync"
+	"time"
+)
+
+// カウンタの値と、リセットされる日時
+type Entry struct {
+	Count   int
+	ResetAt time.Time
+}
+
+type IStore interface {
+	Incr(key string, window time.Duration) (Entry, error) // 期限切れならリセットしてから1増やす
+	Get(key string) (Entry, error)                        // ない・期限切れならゼロ値
+	Set(key string, count int, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// 1台で動かす場合のメモリ上のストア
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	calls   int // 期限切れのエントリを掃除する間隔を数える
+}
+
+func NewMemoryStore() IStore {
+	return &memoryStore{entries: map[string]Entry{}}
+}
+
+func (ms *memoryStore) Incr(key string, window time.Duration) (Entry, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	ms.sweep(now)
+	entry, ok := ms.entries[key]
+	if !ok || !now.Before(entry.ResetAt) {
+		entry = Entry{ResetAt: now.Add(window)}
+	}
+	entry.Count++
+	ms.entries[key] = entry
+	return entry, nil
+}
+
+func (ms *memoryStore) Get(key string) (Entry, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	entry, ok := ms.entries[key]
+	if !ok || !time.Now().Before(entry.ResetAt) {
+		return Entry{}, nil
+	}
+	return entry, nil
+}
+
+func (ms *memoryStore) Set(key string, count int, ttl time.Duration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.entries[key] = Entry{Count: count, ResetAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (ms *memoryStore) Delete(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.entries, key)
+	return nil
+}
+
+// 1000回に1回、期限切れのエントリを削除してメモリが増え続けないようにする
+func (ms *memoryStore) sweep(now time.Time) {
+	ms.calls++
+	if ms.calls%1000 != 0 {
+		return
+	}
+	for key, entry := range ms.entries {
+		if !now.Before(entry.ResetAt) {
+			delete(ms.entries, key)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+/* 管理者用のCLIで使う、テーブルをまたいだデータベース操作 */
+
+import (
+	"bulletin-board-rest-api/model"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IAdminRepository interface {
+	PurgeExpired(ctx context.Context, result *model.PurgeResult, now time.Time, notificationsBefore time.Time) error // 期限切れのデータを削除する
+	GetStats(ctx context.Context, stats *model.AdminStats, now time.Time) error
+}
+
+type adminRepository struct {
+	db *gorm.DB
+}
+
+func NewAdminRepository(db *gorm.DB) IAdminRepository {
+	return &adminRepository{db}
+}
+
+func (ar *adminRepository) PurgeExpired(ctx context.Context, result *model.PurgeResult, now time.Time, notificationsBefore time.Time) error {
+	return transaction(ctx, ar.db, func(ctx context.Context) error {
+		tx := conn(ctx, ar.db)
+		res := tx.Exec("DELETE FROM rate_limits WHERE reset_at <= ?", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.RateLimits = res.RowsAffected
+
+		// 期限切れ（ゼロ値は期限なし）・使用回数の上限に達した招待トークン
+		res = tx.Where("(expires_at > to_timestamp(0) AND expires_at <= ?) OR (max_uses > 0 AND uses >= max_uses)", now).
+			Delete(&model.QuestInvite{})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Invites = res.RowsAffected
+
+		res = tx.Model(&model.User{}).Where("email_token <> '' AND email_token_expires_at <= ?", now).
+			Updates(map[string]interface{}{"pending_email": "", "email_token": ""})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.EmailTokens = res.RowsAffected
+
+		res = tx.Where("read = ? AND created_at < ?", true, notificationsBefore).Delete(&model.Notification{})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Notifications = res.RowsAffected
+		return nil
+	})
+}
+
+func (ar *adminRepository) GetStats(ctx context.Context, stats *model.AdminStats, now time.Time) error {
+	counts := []struct {
+		dest  *int64
+		query *gorm.DB
+	}{
+		{&stats.Users, conn(ctx, ar.db).Model(&model.User{})},
+		{&stats.Admins, conn(ctx, ar.db).Model(&model.User{}).Where("is_admin = ?", true)},
+		{&stats.SuspendedUsers, conn(ctx, ar.db).Model(&model.User{}).Where("suspended = ?", true)},
+		{&stats.Quests, conn(ctx, ar.db).Model(&model.Quest{})},
+		{&stats.UpcomingQuests, conn(ctx, ar.db).Model(&model.Quest{}).Where("start_time > ?", now)},
+		{&stats.Participations, conn(ctx, ar.db).Model(&model.QuestParticipant{}).Where("status = ?", model.ParticipantApproved)},
+		{&stats.PendingRequests, conn(ctx, ar.db).Model(&model.QuestParticipant{}).Where("status = ?", model.ParticipantPending)},
+		{&stats.Tags, conn(ctx, ar.db).Model(&model.Tag{})},
+		{&stats.Categories, conn(ctx, ar.db).Model(&model.Category{})},
+	}
+	for _, c := range counts {
+		if err := c.query.Count(c.dest).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+/* データベース操作 */
+
+import (
+	"bulletin-board-rest-api/model"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type ICategoryRepository interface {
+	GetAllCategories(ctx context.Context, categories *[]model.Category) error
+	GetCategoryById(ctx context.Context, category *model.Category, categoryId uint) error
+	CreateCategory(ctx context.Context, category *model.Category) error // category.Fieldsも保存する
+	UpdateCategory(ctx context.Context, category *model.Category, categoryId uint) error
+	DeleteCategory(ctx context.Context, categoryId uint) error
+}
+
+type categoryRepository struct {
+	db *gorm.DB
+}
+
+func NewCategoryRepository(db *gorm.DB) ICategoryRepository {
+	return &categoryRepository{db}
+}
+
+func (cr *categoryRepository) GetAllCategories(ctx context.Context, categories *[]model.Category) error {
+	if err := conn(ctx, cr.db).Preload("Fields", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Order("id").Find(categories).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *categoryRepository) GetCategoryById(ctx context.Context, category *model.Category, categoryId uint) error {
+	if err := conn(ctx, cr.db).Preload("Fields").First(category, categoryId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *categoryRepository) CreateCategory(ctx context.Context, category *model.Category) error {
+	if err := conn(ctx, cr.db).Create(category).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cr *categoryRepository) UpdateCategory(ctx context.Context, category *model.Category, categoryId uint) error {
+	// カテゴリ本体の更新と追加項目の置き換えを1つのトランザクションで行う
+	return transaction(ctx, cr.db, func(ctx context.Context) error {
+		tx := conn(ctx, cr.db)
+		result := tx.Model(&model.Category{}).Where("id = ?", categoryId).Updates(map[string]interface{}{
+			"name":  category.Name,
+			"icon":  category.Icon,
+			"color": category.Color,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return fmt.Errorf("object does not exist")
+		}
+		if err := tx.Where("category_id = ?", categoryId).Delete(&model.CategoryField{}).Error; err != nil {
+			return err
+		}
+		for i := range category.Fields {
+			category.Fields[i].ID = 0
+			category.Fields[i].CategoryId = categoryId
+		}
+		if len(category.Fields) > 0 {
+			if err := tx.Create(&category.Fields).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (cr *categoryRepository) DeleteCategory(ctx context.Context, categoryId uint) error {
+	// クエストのcategory_idは外部キー制約によりNULLになる
+	result := conn(ctx, cr.db).Delete(&model.Category{}, categoryId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+/* DBの制約違反をドメインのエラーに変換する */
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrQuestFull          = errors.New("quest is full")                     // 定員に達していて参加・承認できない
+	ErrAlreadyJoined      = errors.New("already joined this quest")         // 同じクエストに2件目の参加記録を作ろうとした
+	ErrDuplicate          = errors.New("record already exists")             // その他の一意制約違反
+	ErrInvalidQuestTimes  = errors.New("quest times are out of order")      // 開始・終了・締切の前後関係が正しくない
+	ErrInvalidValue       = errors.New("value violates a check constraint") // その他のCHECK制約違反
+	ErrReferenceNotExists = errors.New("referenced record does not exist")  // 外部キーの参照先がない
+)
+
+// PostgreSQLのエラーコード
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+)
+
+// 制約違反であれば対応するドメインのエラーを返し、それ以外はそのまま返す
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		if pgErr.ConstraintName == "uq_quest_participants_quest_user" {
+			return ErrAlreadyJoined
+		}
+		return ErrDuplicate
+	case pgCheckViolation:
+		if pgErr.ConstraintName == "chk_quests_time_order" {
+			return ErrInvalidQuestTimes
+		}
+		return ErrInvalidValue
+	case pgForeignKeyViolation:
+		return ErrReferenceNotExists
+	}
+	return err
+}
@@ -0,0 +1,245 @@
+package memory
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func newUser(t *testing.T, ur repository.IUserRepository, userName string) model.User {
+	t.Helper()
+	user := model.User{Email: userName + "@example.com", Password: "hash", UserName: userName}
+	if err := ur.CreateUser(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestUserRepository(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	ur := NewUserRepository(s)
+	taro := newUser(t, ur, "taro")
+
+	tests := []struct {
+		name    string
+		run     func() error
+		wantErr error
+		wantMsg string
+	}{
+		{name: "メールアドレスが重複", run: func() error {
+			return ur.CreateUser(ctx, &model.User{Email: taro.Email, UserName: "other"})
+		}, wantErr: repository.ErrDuplicate},
+		{name: "存在しないユーザーの取得", run: func() error {
+			return ur.GetUserByID(ctx, &model.User{}, 999)
+		}, wantErr: gorm.ErrRecordNotFound},
+		{name: "存在しないメールアドレス", run: func() error {
+			return ur.GetUserByEmail(ctx, &model.User{}, "nobody@example.com")
+		}, wantErr: gorm.ErrRecordNotFound},
+		{name: "存在しないユーザーを管理者にする", run: func() error {
+			return ur.SetAdmin(ctx, 999, true)
+		}, wantMsg: "object does not exist"},
+		{name: "メールアドレス変更の申請がない", run: func() error {
+			return ur.ConfirmPendingEmail(ctx, taro.ID)
+		}, wantMsg: "object does not exist"},
+		{name: "存在しないユーザーに引き継いで退会", run: func() error {
+			return ur.DeleteAccount(ctx, taro.ID, 999)
+		}, wantErr: repository.ErrReferenceNotExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantMsg != "" && (err == nil || err.Error() != tt.wantMsg) {
+				t.Errorf("error = %v, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestQuestRepository_Ownership(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	ur, qr := NewUserRepository(s), NewQuestRepository(s)
+	owner := newUser(t, ur, "owner")
+	other := newUser(t, ur, "other")
+	quest := model.Quest{Title: "勉強会", UserId: owner.ID}
+	if err := qr.CreateQuest(ctx, &quest); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		run     func(userId uint) error
+		wantErr error
+		wantMsg string
+	}{
+		{name: "取得", run: func(userId uint) error {
+			return qr.GetQuestById(ctx, &model.Quest{}, userId, quest.ID)
+		}, wantErr: gorm.ErrRecordNotFound},
+		{name: "更新", run: func(userId uint) error {
+			return qr.UpdateQuest(ctx, &model.Quest{Title: "乗っ取り", Visibility: model.VisibilityPublic}, userId, quest.ID)
+		}, wantMsg: "object does not exist"},
+		{name: "参加申請の一覧", run: func(userId uint) error {
+			return qr.GetJoinRequests(ctx, &[]model.QuestParticipant{}, userId, quest.ID)
+		}, wantErr: gorm.ErrRecordNotFound},
+		{name: "招待トークンの作成", run: func(userId uint) error {
+			return qr.CreateInvite(ctx, &model.QuestInvite{Token: fmt.Sprint("token-", userId), QuestId: quest.ID}, userId)
+		}, wantErr: gorm.ErrRecordNotFound},
+		{name: "参加禁止", run: func(userId uint) error {
+			return qr.BanParticipant(ctx, userId, quest.ID, owner.ID, "")
+		}, wantErr: gorm.ErrRecordNotFound},
+		{name: "削除", run: func(userId uint) error {
+			return qr.DeleteQuest(ctx, userId, quest.ID)
+		}, wantMsg: "object does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 募集主以外は存在しないものとして扱う
+			err := tt.run(other.ID)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantMsg != "" && (err == nil || err.Error() != tt.wantMsg) {
+				t.Errorf("error = %v, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+
+	// 募集主以外の操作で何も変わっていない
+	got := model.Quest{}
+	if err := qr.GetQuestById(ctx, &got, owner.ID, quest.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != quest.Title {
+		t.Errorf("Title = %q, want %q", got.Title, quest.Title)
+	}
+}
+
+// 同時に参加しても定員を超えない
+func TestJoinQuest_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	ur, qr := NewUserRepository(s), NewQuestRepository(s)
+	owner := newUser(t, ur, "owner")
+	quest := model.Quest{Title: "定員5人", UserId: owner.ID, MaxParticipants: 5}
+	if err := qr.CreateQuest(ctx, &quest); err != nil {
+		t.Fatal(err)
+	}
+	users := []model.User{}
+	for i := 0; i < 20; i++ {
+		users = append(users, newUser(t, ur, fmt.Sprint("user", i)))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(users))
+	for _, user := range users {
+		wg.Add(1)
+		go func(userId uint) {
+			defer wg.Done()
+			errs <- qr.JoinQuest(ctx, userId, quest.ID, "")
+		}(user.ID)
+	}
+	wg.Wait()
+	close(errs)
+
+	joined, full := 0, 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			joined++
+		case errors.Is(err, repository.ErrQuestFull):
+			full++
+		default:
+			t.Errorf("JoinQuest() error = %v", err)
+		}
+	}
+	if joined != 5 || full != 15 {
+		t.Errorf("joined = %d, full = %d, want 5, 15", joined, full)
+	}
+	participants := []model.QuestParticipant{}
+	if err := qr.GetParticipants(ctx, &participants, quest.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(participants) != 5 {
+		t.Errorf("len(participants) = %d, want 5", len(participants))
+	}
+}
+
+func TestTransactionManager(t *testing.T) {
+	ctx := context.Background()
+	errFail := errors.New("fail")
+
+	tests := []struct {
+		name      string
+		run       func(tm repository.ITransactionManager, ur repository.IUserRepository) error
+		wantUsers []string
+	}{
+		{name: "成功したら確定する", run: func(tm repository.ITransactionManager, ur repository.IUserRepository) error {
+			return tm.Do(ctx, func(ctx context.Context) error {
+				return ur.CreateUser(ctx, &model.User{Email: "a@example.com", UserName: "a"})
+			})
+		}, wantUsers: []string{"a"}},
+		{name: "失敗したら全て取り消す", run: func(tm repository.ITransactionManager, ur repository.IUserRepository) error {
+			return tm.Do(ctx, func(ctx context.Context) error {
+				if err := ur.CreateUser(ctx, &model.User{Email: "a@example.com", UserName: "a"}); err != nil {
+					return err
+				}
+				return errFail
+			})
+		}},
+		{name: "内側の失敗は内側だけ取り消す", run: func(tm repository.ITransactionManager, ur repository.IUserRepository) error {
+			return tm.Do(ctx, func(ctx context.Context) error {
+				if err := ur.CreateUser(ctx, &model.User{Email: "a@example.com", UserName: "a"}); err != nil {
+					return err
+				}
+				err := tm.Do(ctx, func(ctx context.Context) error {
+					if err := ur.CreateUser(ctx, &model.User{Email: "b@example.com", UserName: "b"}); err != nil {
+						return err
+					}
+					return errFail
+				})
+				if !errors.Is(err, errFail) {
+					return fmt.Errorf("nested Do() error = %v", err)
+				}
+				return nil
+			})
+		}, wantUsers: []string{"a"}},
+		{name: "外側の失敗は内側の変更も取り消す", run: func(tm repository.ITransactionManager, ur repository.IUserRepository) error {
+			return tm.Do(ctx, func(ctx context.Context) error {
+				if err := tm.Do(ctx, func(ctx context.Context) error {
+					return ur.CreateUser(ctx, &model.User{Email: "b@example.com", UserName: "b"})
+				}); err != nil {
+					return err
+				}
+				return errFail
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore()
+			ur := NewUserRepository(s)
+			err := tt.run(NewTransactionManager(s), ur)
+			if err != nil && !errors.Is(err, errFail) {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"a", "b"} {
+				want := slices.Contains(tt.wantUsers, name)
+				err := ur.GetUserByUserName(ctx, &model.User{}, name)
+				if (err == nil) != want {
+					t.Errorf("user %q exists = %v, want %v", name, err == nil, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"context"
+	"sort"
+	"time"
+)
+
+type notificationRepository struct {
+	s *Store
+}
+
+func NewNotificationRepository(s *Store) repository.INotificationRepository {
+	return &notificationRepository{s}
+}
+
+func (nr *notificationRepository) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	defer nr.s.lock(ctx)()
+	if _, ok := nr.s.users[notification.UserId]; !ok {
+		return repository.ErrReferenceNotExists
+	}
+	notification.ID = nr.s.nextID("notifications")
+	notification.CreatedAt = time.Now()
+	stored := *notification
+	stored.User = model.User{}
+	nr.s.notifications[notification.ID] = stored
+	return nil
+}
+
+func (nr *notificationRepository) GetNotifications(ctx context.Context, notifications *[]model.Notification, userId uint) error {
+	defer nr.s.lock(ctx)()
+	list := []model.Notification{}
+	for _, n := range nr.s.notifications {
+		if n.UserId == userId {
+			list = append(list, n)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	*notifications = list
+	return nil
+}
+
+func (nr *notificationRepository) MarkAsRead(ctx context.Context, userId uint) error {
+	defer nr.s.lock(ctx)()
+	for id, n := range nr.s.notifications {
+		if n.UserId == userId && !n.Read {
+			n.Read = true
+			nr.s.notifications[id] = n
+		}
+	}
+	return nil
+}
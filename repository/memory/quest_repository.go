@@ -0,0 +1,673 @@
+package memory
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type questRepository struct {
+	s *Store
+}
+
+func NewQuestRepository(s *Store) repository.IQuestRepository {
+	return &questRepository{s}
+}
+
+//* 一覧
+
+func (qr *questRepository) GetAllQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, viewerId uint, filter model.QuestFilter) error {
+	defer qr.s.lock(ctx)()
+	*quests = qr.s.summaries(viewerId, func(q model.Quest) bool {
+		return q.Visibility == model.VisibilityPublic && matchTags(q, filter)
+	}, byCreatedAtDesc)
+	return nil
+}
+
+func (qr *questRepository) GetUserQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error {
+	defer qr.s.lock(ctx)()
+	*quests = qr.s.summaries(userId, func(q model.Quest) bool { return q.UserId == userId }, byStartTimeDesc)
+	return nil
+}
+
+func (qr *questRepository) GetJoinedQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error {
+	defer qr.s.lock(ctx)()
+	*quests = qr.s.summaries(userId, func(q model.Quest) bool {
+		p, ok := qr.s.findParticipant(q.ID, userId)
+		return ok && p.Status != model.ParticipantBanned
+	}, byStartTimeDesc)
+	return nil
+}
+
+func (qr *questRepository) GetPublicUserQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error {
+	defer qr.s.lock(ctx)()
+	*quests = qr.s.summaries(0, func(q model.Quest) bool {
+		return q.UserId == userId && q.Visibility == model.VisibilityPublic
+	}, byStartTimeDesc)
+	return nil
+}
+
+func (qr *questRepository) GetPublicJoinedQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error {
+	defer qr.s.lock(ctx)()
+	*quests = qr.s.summaries(0, func(q model.Quest) bool {
+		p, ok := qr.s.findParticipant(q.ID, userId)
+		return ok && p.Status == model.ParticipantApproved && q.Visibility == model.VisibilityPublic
+	}, byStartTimeDesc)
+	return nil
+}
+
+//* 1件の取得・作成・更新・削除
+
+func (qr *questRepository) GetQuestById(ctx context.Context, quest *model.Quest, userId uint, questId uint) error {
+	defer qr.s.lock(ctx)()
+	q, ok := qr.s.quests[questId]
+	if !ok || q.UserId != userId {
+		return gorm.ErrRecordNotFound
+	}
+	*quest = cloneQuest(q)
+	quest.User = cloneUser(qr.s.users[q.UserId])
+	return nil
+}
+
+func (qr *questRepository) GetQuestForView(ctx context.Context, quest *model.QuestSummary, viewerId uint, questId uint) error {
+	defer qr.s.lock(ctx)()
+	q, ok := qr.s.quests[questId]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	*quest = qr.s.summary(q, viewerId)
+	return nil
+}
+
+func (qr *questRepository) GetParticipants(ctx context.Context, participants *[]model.QuestParticipant, questId uint) error {
+	defer qr.s.lock(ctx)()
+	*participants = qr.s.participantsOf(questId, model.ParticipantApproved)
+	return nil
+}
+
+func (qr *questRepository) CreateQuest(ctx context.Context, quest *model.Quest) error {
+	defer qr.s.lock(ctx)()
+	if quest.Visibility == "" {
+		quest.Visibility = model.VisibilityPublic // カラムのデフォルト値
+	}
+	if _, ok := qr.s.users[quest.UserId]; !ok {
+		return repository.ErrReferenceNotExists
+	}
+	if err := checkQuest(*quest); err != nil {
+		return err
+	}
+	now := time.Now()
+	quest.ID = qr.s.nextID("quests")
+	quest.CreatedAt, quest.UpdatedAt = now, now
+	qr.s.quests[quest.ID] = cloneQuest(*quest)
+	return nil
+}
+
+// GORMの実装と同じく、category_id・custom_fields・画像は更新しない
+func (qr *questRepository) UpdateQuest(ctx context.Context, quest *model.Quest, userId uint, questId uint) error {
+	defer qr.s.lock(ctx)()
+	q, ok := qr.s.quests[questId]
+	if !ok || q.UserId != userId {
+		return fmt.Errorf("object does not exist")
+	}
+	q.Title = quest.Title
+	q.Description = quest.Description
+	q.Category = quest.Category
+	q.CategoryId = quest.CategoryId
+	q.CustomFields = quest.CustomFields
+	q.MaxParticipants = quest.MaxParticipants
+	q.Deadline = quest.Deadline
+	q.StartTime = quest.StartTime
+	q.EndTime = quest.EndTime
+	q.URL = quest.URL
+	q.RequiresApproval = quest.RequiresApproval
+	q.Visibility = quest.Visibility
+	if err := checkQuest(q); err != nil {
+		return err
+	}
+	q.UpdatedAt = time.Now()
+	qr.s.quests[questId] = cloneQuest(q)
+	*quest = cloneQuest(q) // RETURNINGで更新後の行を受け取るのと同じ
+	return nil
+}
+
+func (qr *questRepository) ReplaceQuestTags(ctx context.Context, quest *model.Quest, tags []model.Tag) error {
+	defer qr.s.lock(ctx)()
+	q, ok := qr.s.quests[quest.ID]
+	if !ok {
+		return repository.ErrReferenceNotExists
+	}
+	q.Tags = tags
+	qr.s.quests[quest.ID] = cloneQuest(q)
+	return nil
+}
+
+func (qr *questRepository) DeleteQuest(ctx context.Context, userId uint, questId uint) error {
+	defer qr.s.lock(ctx)()
+	q, ok := qr.s.quests[questId]
+	if !ok || q.UserId != userId {
+		return fmt.Errorf("object does not exist")
+	}
+	qr.s.deleteQuest(questId)
+	return nil
+}
+
+//* 参加
+
+func (qr *questRepository) JoinQuest(ctx context.Context, userId uint, questId uint, token string) error {
+	defer qr.s.lock(ctx)()
+	s := qr.s
+	quest, ok := s.quests[questId]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if existing, ok := s.findParticipant(questId, userId); ok {
+		if existing.Status == model.ParticipantRejected {
+			return fmt.Errorf("join request was rejected")
+		}
+		if existing.Status == model.ParticipantBanned {
+			return fmt.Errorf("you are banned from this quest")
+		}
+		return nil // 既に参加・申請している場合は何もせずに終了
+	}
+	if _, ok := s.users[userId]; !ok {
+		return repository.ErrReferenceNotExists
+	}
+	// 招待トークンを使ってから定員で弾かれた場合は、トランザクションのロールバックと同じく使用回数を戻す
+	snap := s.snapshot()
+	if quest.Visibility == model.VisibilityInviteOnly {
+		if err := s.useInvite(questId, token); err != nil {
+			return err
+		}
+	}
+	status := model.ParticipantApproved
+	if quest.RequiresApproval {
+		status = model.ParticipantPending
+	} else if err := s.checkCapacity(quest); err != nil {
+		s.restore(snap)
+		return err
+	}
+	s.addParticipant(model.QuestParticipant{JoinedAt: time.Now().In(jst), UserId: userId, QuestId: questId, Status: status})
+	return nil
+}
+
+func (qr *questRepository) CancelQuest(ctx context.Context, userId uint, questId uint) error {
+	defer qr.s.lock(ctx)()
+	p, ok := qr.s.findParticipant(questId, userId)
+	if !ok || (p.Status != model.ParticipantApproved && p.Status != model.ParticipantPending) {
+		return fmt.Errorf("object does not exist")
+	}
+	delete(qr.s.participants, p.ID)
+	qr.s.touchQuest(questId)
+	return nil
+}
+
+//* 募集主による参加者の管理
+
+func (qr *questRepository) GetJoinRequests(ctx context.Context, participants *[]model.QuestParticipant, userId uint, questId uint) error {
+	defer qr.s.lock(ctx)()
+	if !qr.s.owns(userId, questId) {
+		return gorm.ErrRecordNotFound
+	}
+	*participants = qr.s.participantsOf(questId, model.ParticipantPending)
+	return nil
+}
+
+func (qr *questRepository) DecideJoinRequest(ctx context.Context, userId uint, questId uint, participantId uint, status string, message string) error {
+	defer qr.s.lock(ctx)()
+	s := qr.s
+	if !s.owns(userId, questId) {
+		return gorm.ErrRecordNotFound
+	}
+	if status == model.ParticipantApproved {
+		if err := s.checkCapacity(s.quests[questId]); err != nil {
+			return err
+		}
+	}
+	p, ok := s.findParticipant(questId, participantId)
+	if !ok || p.Status != model.ParticipantPending {
+		return fmt.Errorf("object does not exist")
+	}
+	if err := checkStatus(status); err != nil {
+		return err
+	}
+	p.Status = status
+	p.Message = message
+	if status == model.ParticipantApproved {
+		p.JoinedAt = time.Now().In(jst)
+	}
+	s.participants[p.ID] = p
+	s.touchQuest(questId)
+	return nil
+}
+
+func (qr *questRepository) RemoveParticipant(ctx context.Context, userId uint, questId uint, participantId uint) error {
+	defer qr.s.lock(ctx)()
+	s := qr.s
+	if !s.owns(userId, questId) {
+		return gorm.ErrRecordNotFound
+	}
+	p, ok := s.findParticipant(questId, participantId)
+	if !ok || (p.Status != model.ParticipantApproved && p.Status != model.ParticipantPending) {
+		return fmt.Errorf("object does not exist")
+	}
+	delete(s.participants, p.ID)
+	s.touchQuest(questId)
+	return nil
+}
+
+func (qr *questRepository) BanParticipant(ctx context.Context, userId uint, questId uint, participantId uint, reason string) error {
+	defer qr.s.lock(ctx)()
+	s := qr.s
+	if !s.owns(userId, questId) {
+		return gorm.ErrRecordNotFound
+	}
+	if participantId == userId {
+		return fmt.Errorf("cannot ban yourself")
+	}
+	if p, ok := s.findParticipant(questId, participantId); ok {
+		p.Status = model.ParticipantBanned
+		p.Message = reason
+		s.participants[p.ID] = p
+	} else {
+		if _, ok := s.users[participantId]; !ok {
+			return repository.ErrReferenceNotExists
+		}
+		s.addParticipant(model.QuestParticipant{
+			JoinedAt: time.Now().In(jst), UserId: participantId, QuestId: questId,
+			Status: model.ParticipantBanned, Message: reason,
+		})
+	}
+	s.touchQuest(questId)
+	return nil
+}
+
+//* 招待トークン
+
+func (qr *questRepository) CreateInvite(ctx context.Context, invite *model.QuestInvite, userId uint) error {
+	defer qr.s.lock(ctx)()
+	s := qr.s
+	if !s.owns(userId, invite.QuestId) {
+		return gorm.ErrRecordNotFound
+	}
+	for _, other := range s.invites {
+		if other.Token == invite.Token {
+			return repository.ErrDuplicate
+		}
+	}
+	invite.ID = s.nextID("quest_invites")
+	invite.CreatedAt = time.Now()
+	stored := *invite
+	stored.Quest = model.Quest{}
+	s.invites[invite.ID] = stored
+	return nil
+}
+
+func (qr *questRepository) GetInvites(ctx context.Context, invites *[]model.QuestInvite, userId uint, questId uint) error {
+	defer qr.s.lock(ctx)()
+	if !qr.s.owns(userId, questId) {
+		return gorm.ErrRecordNotFound
+	}
+	list := []model.QuestInvite{}
+	for _, invite := range qr.s.invites {
+		if invite.QuestId == questId {
+			list = append(list, invite)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	*invites = list
+	return nil
+}
+
+func (qr *questRepository) DeleteInvite(ctx context.Context, userId uint, questId uint, token string) error {
+	defer qr.s.lock(ctx)()
+	if !qr.s.owns(userId, questId) {
+		return gorm.ErrRecordNotFound
+	}
+	for id, invite := range qr.s.invites {
+		if invite.QuestId == questId && invite.Token == token {
+			delete(qr.s.invites, id)
+			return nil
+		}
+	}
+	return fmt.Errorf("object does not exist")
+}
+
+func (qr *questRepository) CheckInvite(ctx context.Context, questId uint, token string) error {
+	defer qr.s.lock(ctx)()
+	if _, ok := qr.s.validInvite(questId, token); !ok {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+//* 条件付きGETの更新状況
+
+func (qr *questRepository) GetQuestsStamp(ctx context.Context, stamp *model.QuestStamp) error {
+	defer qr.s.lock(ctx)()
+	stamp.UpdatedAt, stamp.QuestCount = epoch, 0
+	for _, q := range qr.s.quests {
+		if q.UpdatedAt.After(stamp.UpdatedAt) {
+			stamp.UpdatedAt = q.UpdatedAt
+		}
+		stamp.QuestCount++
+	}
+	qr.s.participantStamp(stamp, 0)
+	qr.s.userStamp(stamp, 0)
+	return nil
+}
+
+func (qr *questRepository) GetQuestStamp(ctx context.Context, stamp *model.QuestStamp, questId uint) error {
+	defer qr.s.lock(ctx)()
+	q, ok := qr.s.quests[questId]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	stamp.UpdatedAt = q.UpdatedAt
+	stamp.QuestCount = 1
+	qr.s.participantStamp(stamp, questId)
+	qr.s.userStamp(stamp, questId)
+	return nil
+}
+
+func (qr *questRepository) TransferQuests(ctx context.Context, fromUserId uint, toUserId uint, questId uint) (int64, error) {
+	defer qr.s.lock(ctx)()
+	if _, ok := qr.s.users[toUserId]; !ok {
+		return 0, repository.ErrReferenceNotExists
+	}
+	transferred := qr.s.transferQuests(fromUserId, toUserId, questId)
+	if questId != 0 && transferred < 1 {
+		return 0, fmt.Errorf("object does not exist")
+	}
+	return transferred, nil
+}
+
+//* Storeの操作（呼び出し元でロックしておく）
+
+func (s *Store) owns(userId uint, questId uint) bool {
+	q, ok := s.quests[questId]
+	return ok && q.UserId == userId
+}
+
+func (s *Store) findParticipant(questId uint, userId uint) (model.QuestParticipant, bool) {
+	for _, p := range s.participants {
+		if p.QuestId == questId && p.UserId == userId {
+			return p, true
+		}
+	}
+	return model.QuestParticipant{}, false
+}
+
+// 指定した状態の参加者を参加した順に返す（Userも読み込む）
+func (s *Store) participantsOf(questId uint, status string) []model.QuestParticipant {
+	list := []model.QuestParticipant{}
+	for _, p := range s.participants {
+		if p.QuestId == questId && p.Status == status {
+			p.User = cloneUser(s.users[p.UserId])
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].JoinedAt.Equal(list[j].JoinedAt) {
+			return list[i].JoinedAt.Before(list[j].JoinedAt)
+		}
+		return list[i].UserId < list[j].UserId
+	})
+	return list
+}
+
+// 1人のユーザーは1つのクエストに1件だけ参加記録を持つ（一意制約）
+func (s *Store) addParticipant(p model.QuestParticipant) {
+	p.ID = s.nextID("quest_participants")
+	s.participants[p.ID] = p
+}
+
+func (s *Store) checkCapacity(quest model.Quest) error {
+	if quest.MaxParticipants == 0 {
+		return nil
+	}
+	var count int64
+	for _, p := range s.participants {
+		if p.QuestId == quest.ID && p.Status == model.ParticipantApproved {
+			count++
+		}
+	}
+	if count >= int64(quest.MaxParticipants) {
+		return repository.ErrQuestFull
+	}
+	return nil
+}
+
+func (s *Store) useInvite(questId uint, token string) error {
+	if token == "" {
+		return fmt.Errorf("invite token is required")
+	}
+	id, ok := s.validInvite(questId, token)
+	if !ok {
+		return fmt.Errorf("invalid or expired invite token")
+	}
+	invite := s.invites[id]
+	invite.Uses++
+	s.invites[id] = invite
+	return nil
+}
+
+// 有効期限内・使用回数の上限内の招待トークンを探す
+func (s *Store) validInvite(questId uint, token string) (uint, bool) {
+	now := time.Now()
+	for id, invite := range s.invites {
+		if invite.QuestId != questId || invite.Token != token {
+			continue
+		}
+		if invite.ExpiresAt.After(epoch) && !invite.ExpiresAt.After(now) {
+			return 0, false
+		}
+		if invite.MaxUses != 0 && invite.Uses >= invite.MaxUses {
+			return 0, false
+		}
+		return id, true
+	}
+	return 0, false
+}
+
+// 参加者の変化を条件付きGETのLast-Modifiedに反映させる
+func (s *Store) touchQuest(questId uint) {
+	if q, ok := s.quests[questId]; ok {
+		q.UpdatedAt = time.Now()
+		s.quests[questId] = q
+	}
+}
+
+// ON DELETE CASCADEと同じく参加記録・招待トークンも削除する
+func (s *Store) deleteQuest(questId uint) {
+	delete(s.quests, questId)
+	for id, p := range s.participants {
+		if p.QuestId == questId {
+			delete(s.participants, id)
+		}
+	}
+	for id, invite := range s.invites {
+		if invite.QuestId == questId {
+			delete(s.invites, id)
+		}
+	}
+}
+
+// 引き継ぎ先が参加者として登録されていれば、主催者になるので参加記録を外す
+func (s *Store) transferQuests(fromUserId uint, toUserId uint, questId uint) int64 {
+	var transferred int64
+	now := time.Now()
+	for id, q := range s.quests {
+		if q.UserId != fromUserId || (questId != 0 && id != questId) {
+			continue
+		}
+		if p, ok := s.findParticipant(id, toUserId); ok {
+			delete(s.participants, p.ID)
+		}
+		q.UserId = toUserId
+		q.UpdatedAt = now
+		s.quests[id] = q
+		transferred++
+	}
+	return transferred
+}
+
+func (s *Store) participantStamp(stamp *model.QuestStamp, questId uint) {
+	stamp.JoinedAt, stamp.ParticipantCount = epoch, 0
+	for _, p := range s.participants {
+		if questId != 0 && p.QuestId != questId {
+			continue
+		}
+		if p.JoinedAt.After(stamp.JoinedAt) {
+			stamp.JoinedAt = p.JoinedAt
+		}
+		stamp.ParticipantCount++
+	}
+}
+
+// 募集主・参加者のユーザー情報の最終更新日時（questIdが0なら全てのクエスト）
+func (s *Store) userStamp(stamp *model.QuestStamp, questId uint) {
+	stamp.UserUpdatedAt = epoch
+	latest := func(userId uint) {
+		if u, ok := s.users[userId]; ok && u.UpdatedAt.After(stamp.UserUpdatedAt) {
+			stamp.UserUpdatedAt = u.UpdatedAt
+		}
+	}
+	for id, q := range s.quests {
+		if questId == 0 || id == questId {
+			latest(q.UserId)
+		}
+	}
+	for _, p := range s.participants {
+		if questId == 0 || p.QuestId == questId {
+			latest(p.UserId)
+		}
+	}
+}
+
+// 一覧表示用の集計（GORMの実装のsummariesと同じ内容）
+func (s *Store) summary(q model.Quest, viewerId uint) model.QuestSummary {
+	approved := s.participantsOf(q.ID, model.ParticipantApproved)
+	preview := model.StringList{}
+	for i, p := range approved {
+		if i >= model.ParticipantPreviewLimit {
+			break
+		}
+		preview = append(preview, p.User.UserName)
+	}
+	joinStatus := ""
+	if p, ok := s.findParticipant(q.ID, viewerId); ok {
+		joinStatus = p.Status
+	}
+	tags := append([]model.Tag(nil), q.Tags...)
+	sort.Slice(tags, func(i, j int) bool { return tags[i].ID < tags[j].ID })
+	tagNames := model.StringList{}
+	for _, tag := range tags {
+		tagNames = append(tagNames, tag.Name)
+	}
+	q = cloneQuest(q)
+	return model.QuestSummary{
+		ID:                 q.ID,
+		Title:              q.Title,
+		Description:        q.Description,
+		Category:           q.Category,
+		CategoryId:         q.CategoryId,
+		CustomFields:       q.CustomFields,
+		MaxParticipants:    q.MaxParticipants,
+		Deadline:           q.Deadline,
+		StartTime:          q.StartTime,
+		EndTime:            q.EndTime,
+		URL:                q.URL,
+		RequiresApproval:   q.RequiresApproval,
+		Visibility:         q.Visibility,
+		CreatedAt:          q.CreatedAt,
+		UpdatedAt:          q.UpdatedAt,
+		UserId:             q.UserId,
+		UserName:           s.users[q.UserId].UserName,
+		ParticipantCount:   int64(len(approved)),
+		ParticipantPreview: preview,
+		JoinStatus:         joinStatus,
+		TagNames:           tagNames,
+	}
+}
+
+func (s *Store) summaries(viewerId uint, match func(q model.Quest) bool, less func(a, b model.Quest) bool) []model.QuestSummary {
+	matched := []model.Quest{}
+	for _, q := range s.quests {
+		if match(q) {
+			matched = append(matched, q)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+	list := make([]model.QuestSummary, 0, len(matched))
+	for _, q := range matched {
+		list = append(list, s.summary(q, viewerId))
+	}
+	return list
+}
+
+// 並び順が同じ場合は、結果が毎回変わらないようにIDの大きい順にする
+func byCreatedAtDesc(a, b model.Quest) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+func byStartTimeDesc(a, b model.Quest) bool {
+	if !a.StartTime.Equal(b.StartTime) {
+		return a.StartTime.After(b.StartTime)
+	}
+	return a.ID > b.ID
+}
+
+// タグで絞り込み（AND：全てのタグを持つ / OR：いずれかのタグを持つ）
+// GORMの実装と同じく、ANDは一致したタグの数を指定されたslugの数と比べる
+func matchTags(q model.Quest, filter model.QuestFilter) bool {
+	if len(filter.Tags) == 0 {
+		return true
+	}
+	matched := 0
+	for _, tag := range q.Tags {
+		if slices.Contains(filter.Tags, tag.Slug) {
+			matched++
+		}
+	}
+	if filter.MatchAll {
+		return matched == len(filter.Tags)
+	}
+	return matched > 0
+}
+
+// CHECK制約（chk_quests_visibility・chk_quests_time_order）と同じ確認
+func checkQuest(q model.Quest) error {
+	switch q.Visibility {
+	case model.VisibilityPublic, model.VisibilityUnlisted, model.VisibilityInviteOnly:
+	default:
+		return repository.ErrInvalidValue
+	}
+	if q.EndTime.After(time.Time{}) && (q.StartTime.After(q.EndTime) || q.Deadline.After(q.EndTime)) {
+		return repository.ErrInvalidQuestTimes
+	}
+	return nil
+}
+
+// CHECK制約（chk_quest_participants_status）と同じ確認
+func checkStatus(status string) error {
+	switch status {
+	case model.ParticipantApproved, model.ParticipantPending, model.ParticipantRejected, model.ParticipantBanned:
+		return nil
+	}
+	return repository.ErrInvalidValue
+}
@@ -0,0 +1,120 @@
+package memory
+
+/*
+Postgresを使わずに動くリポジトリの実装（テスト用）
+ ・全てのリポジトリで1つのStoreを共有し、GORMの実装と同じエラー（gorm.ErrRecordNotFound・"object does not exist"・
+   repository.ErrQuestFull など）を返す
+ ・1回の操作はStoreのロックの中で行うので、複数のゴルーチンから同時に使える
+ ・TransactionManagerのDoの間はロックを持ち続け、失敗したら開始時点の状態に戻す
+*/
+
+import (
+	"bulletin-board-rest-api/model"
+	"context"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu            sync.Mutex
+	users         map[uint]model.User
+	quests        map[uint]model.Quest // User・Participantsは持たず、Tagsだけを持つ
+	participants  map[uint]model.QuestParticipant
+	invites       map[uint]model.QuestInvite
+	notifications map[uint]model.Notification
+	seq           map[string]uint // テーブルごとの採番（Postgresのシーケンスと同じくロールバックしても戻さない）
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:         map[uint]model.User{},
+		quests:        map[uint]model.Quest{},
+		participants:  map[uint]model.QuestParticipant{},
+		invites:       map[uint]model.QuestInvite{},
+		notifications: map[uint]model.Notification{},
+		seq:           map[string]uint{},
+	}
+}
+
+// Doの中であることを示すcontextのキー（Storeごとに別のキーになる）
+type txKey struct {
+	s *Store
+}
+
+// Storeをロックする（Doの中では既にロックしているので何もしない）
+func (s *Store) lock(ctx context.Context) func() {
+	if ctx.Value(txKey{s}) != nil {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *Store) nextID(table string) uint {
+	s.seq[table]++
+	return s.seq[table]
+}
+
+type snapshot struct {
+	users         map[uint]model.User
+	quests        map[uint]model.Quest
+	participants  map[uint]model.QuestParticipant
+	invites       map[uint]model.QuestInvite
+	notifications map[uint]model.Notification
+}
+
+// 保存する値は書き込み時に複製しているので、mapを複製するだけでよい
+func (s *Store) snapshot() snapshot {
+	return snapshot{
+		users:         copyMap(s.users),
+		quests:        copyMap(s.quests),
+		participants:  copyMap(s.participants),
+		invites:       copyMap(s.invites),
+		notifications: copyMap(s.notifications),
+	}
+}
+
+func (s *Store) restore(snap snapshot) {
+	s.users = snap.users
+	s.quests = snap.quests
+	s.participants = snap.participants
+	s.invites = snap.invites
+	s.notifications = snap.notifications
+}
+
+func copyMap[V any](m map[uint]V) map[uint]V {
+	c := make(map[uint]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// 呼び出し元と保存した値でスライスやmapを共有しないように複製する
+func cloneUser(u model.User) model.User {
+	u.Avatar = append([]byte(nil), u.Avatar...)
+	u.Links = append(model.StringList(nil), u.Links...)
+	return u
+}
+
+func cloneQuest(q model.Quest) model.Quest {
+	q.Image = append([]byte(nil), q.Image...)
+	q.Tags = append([]model.Tag(nil), q.Tags...)
+	q.TagNames = append([]string(nil), q.TagNames...)
+	if q.CustomFields != nil {
+		fields := make(model.CustomFields, len(q.CustomFields))
+		for k, v := range q.CustomFields {
+			fields[k] = v
+		}
+		q.CustomFields = fields
+	}
+	q.User = model.User{}
+	q.Participants = nil
+	q.CategoryRef = nil
+	return q
+}
+
+// Postgresのto_timestamp(0)（時刻が未設定のときの比較やMAXの初期値に使う）
+var epoch = time.Unix(0, 0)
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
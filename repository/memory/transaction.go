@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"bulletin-board-rest-api/repository"
+	"context"
+)
+
+type transactionManager struct {
+	s *Store
+}
+
+// Doの間は他の操作を待たせるので、トランザクションは常に直列に実行される（やり直しは起きない）
+func NewTransactionManager(s *Store) repository.ITransactionManager {
+	return &transactionManager{s}
+}
+
+func (tm *transactionManager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	// 既にトランザクションの中ならセーブポイントと同じく、失敗したらこの中の変更だけを取り消す
+	if ctx.Value(txKey{tm.s}) != nil {
+		snap := tm.s.snapshot()
+		if err := fn(ctx); err != nil {
+			tm.s.restore(snap)
+			return err
+		}
+		return nil
+	}
+	tm.s.mu.Lock()
+	defer tm.s.mu.Unlock()
+	snap := tm.s.snapshot()
+	if err := fn(context.WithValue(ctx, txKey{tm.s}, true)); err != nil {
+		tm.s.restore(snap)
+		return err
+	}
+	return nil
+}
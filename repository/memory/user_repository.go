@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type userRepository struct {
+	s *Store
+}
+
+func NewUserRepository(s *Store) repository.IUserRepository {
+	return &userRepository{s}
+}
+
+// 条件に一致する最初のユーザー（IDの小さい順）
+func (s *Store) findUser(match func(u model.User) bool) (model.User, bool) {
+	var found model.User
+	ok := false
+	for _, u := range s.users {
+		if match(u) && (!ok || u.ID < found.ID) {
+			found, ok = u, true
+		}
+	}
+	return found, ok
+}
+
+// メールアドレス・ユーザー名の一意制約
+func (s *Store) checkUserUnique(u model.User) error {
+	_, dup := s.findUser(func(other model.User) bool {
+		return other.ID != u.ID && (other.Email == u.Email || other.UserName == u.UserName)
+	})
+	if dup {
+		return repository.ErrDuplicate
+	}
+	return nil
+}
+
+func (ur *userRepository) GetUserByEmail(ctx context.Context, user *model.User, email string) error {
+	defer ur.s.lock(ctx)()
+	u, ok := ur.s.findUser(func(u model.User) bool { return u.Email == email })
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	*user = cloneUser(u)
+	return nil
+}
+
+func (ur *userRepository) CreateUser(ctx context.Context, user *model.User) error {
+	defer ur.s.lock(ctx)()
+	if err := ur.s.checkUserUnique(*user); err != nil {
+		return err
+	}
+	now := time.Now()
+	user.ID = ur.s.nextID("users")
+	user.CreatedAt, user.UpdatedAt = now, now
+	ur.s.users[user.ID] = cloneUser(*user)
+	return nil
+}
+
+func (ur *userRepository) GetUserByID(ctx context.Context, user *model.User, userId uint) error {
+	defer ur.s.lock(ctx)()
+	u, ok := ur.s.users[userId]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	*user = cloneUser(u)
+	return nil
+}
+
+// 存在しないユーザーの場合は何もしない（GORMの実装と同じく更新件数は確認しない）
+func (ur *userRepository) UpdateUserName(ctx context.Context, userId uint, newUserName string) error {
+	defer ur.s.lock(ctx)()
+	return ur.s.updateUser(userId, func(u *model.User) error {
+		u.UserName = newUserName
+		return ur.s.checkUserUnique(*u)
+	})
+}
+
+func (ur *userRepository) GetUserByUserName(ctx context.Context, user *model.User, userName string) error {
+	defer ur.s.lock(ctx)()
+	u, ok := ur.s.findUser(func(u model.User) bool { return u.UserName == userName })
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	*user = cloneUser(u)
+	return nil
+}
+
+func (ur *userRepository) UpdateProfile(ctx context.Context, userId uint, profile model.UpdateProfileRequest) error {
+	defer ur.s.lock(ctx)()
+	return ur.s.updateUser(userId, func(u *model.User) error {
+		u.DisplayName = profile.DisplayName
+		u.Bio = profile.Bio
+		u.Faculty = profile.Faculty
+		u.Grade = profile.Grade
+		u.Avatar = profile.Avatar
+		u.Links = profile.Links
+		return nil
+	})
+}
+
+// GORMの実装と同じ順序で退会処理を行う
+func (ur *userRepository) DeleteAccount(ctx context.Context, userId uint, transferTo uint) error {
+	defer ur.s.lock(ctx)()
+	s := ur.s
+	if _, ok := s.users[userId]; !ok {
+		return fmt.Errorf("object does not exist")
+	}
+	if transferTo != 0 {
+		if _, ok := s.users[transferTo]; !ok {
+			return repository.ErrReferenceNotExists
+		}
+		s.transferQuests(userId, transferTo, 0)
+	} else {
+		for id, q := range s.quests {
+			if q.UserId == userId {
+				s.deleteQuest(id)
+			}
+		}
+	}
+
+	// 開始日時が未来（または未設定）のクエストへの参加を取り消す
+	now := time.Now()
+	for id, p := range s.participants {
+		q, ok := s.quests[p.QuestId]
+		if p.UserId == userId && ok && (q.StartTime.After(now) || !q.StartTime.After(epoch)) {
+			delete(s.participants, id)
+		}
+	}
+	for id, n := range s.notifications {
+		if n.UserId == userId {
+			delete(s.notifications, id)
+		}
+	}
+
+	u := s.users[userId]
+	u.Email = fmt.Sprintf("deleted-%d@invalid", userId)
+	u.Password = ""
+	u.UserName = fmt.Sprintf("退会済み%d", userId)
+	u.IsAdmin = false
+	u.DisplayName = ""
+	u.Bio = ""
+	u.Faculty = ""
+	u.Grade = 0
+	u.Avatar = nil
+	u.Links = model.StringList{}
+	u.TokenVersion++
+	u.PendingEmail = ""
+	u.EmailToken = ""
+	u.EmailTokenExpiresAt = time.Time{}
+	u.UpdatedAt = now
+	s.users[userId] = u
+	return nil
+}
+
+func (ur *userRepository) UpdatePassword(ctx context.Context, userId uint, hash string) error {
+	defer ur.s.lock(ctx)()
+	return ur.s.updateUser(userId, func(u *model.User) error {
+		u.Password = hash
+		u.TokenVersion++
+		return nil
+	})
+}
+
+func (ur *userRepository) SetPendingEmail(ctx context.Context, userId uint, email string, tokenHash string, expiresAt time.Time) error {
+	defer ur.s.lock(ctx)()
+	return ur.s.updateUser(userId, func(u *model.User) error {
+		u.PendingEmail = email
+		u.EmailToken = tokenHash
+		u.EmailTokenExpiresAt = expiresAt
+		return nil
+	})
+}
+
+func (ur *userRepository) GetUserByEmailToken(ctx context.Context, user *model.User, tokenHash string) error {
+	defer ur.s.lock(ctx)()
+	now := time.Now()
+	u, ok := ur.s.findUser(func(u model.User) bool {
+		return u.EmailToken == tokenHash && u.EmailTokenExpiresAt.After(now)
+	})
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	*user = cloneUser(u)
+	return nil
+}
+
+func (ur *userRepository) ConfirmPendingEmail(ctx context.Context, userId uint) error {
+	defer ur.s.lock(ctx)()
+	u, ok := ur.s.users[userId]
+	if !ok || u.PendingEmail == "" {
+		return fmt.Errorf("object does not exist")
+	}
+	return ur.s.updateUser(userId, func(u *model.User) error {
+		u.Email = u.PendingEmail
+		u.PendingEmail = ""
+		u.EmailToken = ""
+		u.EmailTokenExpiresAt = time.Time{}
+		return ur.s.checkUserUnique(*u)
+	})
+}
+
+func (ur *userRepository) SetAdmin(ctx context.Context, userId uint, isAdmin bool) error {
+	defer ur.s.lock(ctx)()
+	if _, ok := ur.s.users[userId]; !ok {
+		return fmt.Errorf("object does not exist")
+	}
+	return ur.s.updateUser(userId, func(u *model.User) error {
+		u.IsAdmin = isAdmin
+		return nil
+	})
+}
+
+func (ur *userRepository) SetSuspended(ctx context.Context, userId uint, suspended bool) error {
+	defer ur.s.lock(ctx)()
+	if _, ok := ur.s.users[userId]; !ok {
+		return fmt.Errorf("object does not exist")
+	}
+	return ur.s.updateUser(userId, func(u *model.User) error {
+		u.Suspended = suspended
+		if suspended {
+			u.TokenVersion++ // 発行済みのJWTも無効にする
+		}
+		return nil
+	})
+}
+
+// ユーザーがいれば更新する（エラーを返した場合は変更しない）
+func (s *Store) updateUser(userId uint, update func(u *model.User) error) error {
+	u, ok := s.users[userId]
+	if !ok {
+		return nil
+	}
+	if err := update(&u); err != nil {
+		return err
+	}
+	u.UpdatedAt = time.Now()
+	s.users[userId] = cloneUser(u)
+	return nil
+}
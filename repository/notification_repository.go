@@ -0,0 +1,46 @@
+package repository
+
+/* データベース操作 */
+
+import (
+	"bulletin-board-rest-api/model"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type INotificationRepository interface {
+	CreateNotification(ctx context.Context, notification *model.Notification) error
+	GetNotifications(ctx context.Context, notifications *[]model.Notification, userId uint) error // 新しい順に取得
+	MarkAsRead(ctx context.Context, userId uint) error                                            // ユーザーのお知らせを全て既読にする
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) INotificationRepository {
+	return &notificationRepository{db}
+}
+
+func (nr *notificationRepository) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	if err := conn(ctx, nr.db).Create(notification).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (nr *notificationRepository) GetNotifications(ctx context.Context, notifications *[]model.Notification, userId uint) error {
+	if err := conn(ctx, nr.db).Where("user_id = ?", userId).Order("created_at DESC").Find(notifications).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (nr *notificationRepository) MarkAsRead(ctx context.Context, userId uint) error {
+	if err := conn(ctx, nr.db).Model(&model.Notification{}).Where("user_id = ? AND read = ?", userId, false).
+		Update("read", true).Error; err != nil {
+		return err
+	}
+	return nil
+}
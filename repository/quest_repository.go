@@ -4,6 +4,8 @@ package repository
 
 import (
 	"bulletin-board-rest-api/model"
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -12,15 +14,32 @@ import (
 )
 
 type IQuestRepository interface {
-	GetAllQuestsFromDB(quests *[]model.Quest) error
-	GetUserQuestsFromDB(quests *[]model.Quest, userId uint) error   //全クエストを配列に格納する
-	GetJoinedQuestsFromDB(quests *[]model.Quest, userId uint) error //全クエストを配列に格納する
-	GetQuestById(quest *model.Quest, UserId uint, QuestId uint) error
-	CreateQuest(quest *model.Quest) error
-	UpdateQuest(quest *model.Quest, UserId uint, QuestId uint) error
-	DeleteQuest(UserId uint, QuestId uint) error
-	JoinQuest(UserId uint, QuestId uint) error
-	CancelQuest(UserId uint, QuestId uint) error
+	//* 一覧はQuestSummaryとして取得する（viewerIdは参加状態を調べるログインユーザー、0なら調べない）
+	GetAllQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, viewerId uint, filter model.QuestFilter) error
+	GetUserQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error         //全クエストを配列に格納する
+	GetJoinedQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error       //全クエストを配列に格納する
+	GetPublicUserQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error   // 公開プロフィール用：作成した公開クエスト
+	GetPublicJoinedQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error // 公開プロフィール用：参加が確定した公開クエスト
+	GetQuestById(ctx context.Context, quest *model.Quest, UserId uint, QuestId uint) error
+	CreateQuest(ctx context.Context, quest *model.Quest) error // quest.Tagsも中間テーブルに保存する
+	UpdateQuest(ctx context.Context, quest *model.Quest, UserId uint, QuestId uint) error
+	ReplaceQuestTags(ctx context.Context, quest *model.Quest, tags []model.Tag) error // クエストのタグを置き換える
+	DeleteQuest(ctx context.Context, UserId uint, QuestId uint) error
+	GetQuestForView(ctx context.Context, quest *model.QuestSummary, viewerId uint, QuestId uint) error // 作成者以外も含めた詳細表示用に取得する
+	GetParticipants(ctx context.Context, participants *[]model.QuestParticipant, QuestId uint) error   // 参加確定者の一覧（参加した順）
+	JoinQuest(ctx context.Context, UserId uint, QuestId uint, token string) error                      // 招待制のクエストではtokenが必要
+	CancelQuest(ctx context.Context, UserId uint, QuestId uint) error
+	GetJoinRequests(ctx context.Context, participants *[]model.QuestParticipant, UserId uint, QuestId uint) error // 承認待ちの参加申請一覧（募集主のみ）
+	DecideJoinRequest(ctx context.Context, UserId uint, QuestId uint, ParticipantId uint, status string, message string) error
+	RemoveParticipant(ctx context.Context, UserId uint, QuestId uint, ParticipantId uint) error             // 参加者を外す（募集主のみ）
+	BanParticipant(ctx context.Context, UserId uint, QuestId uint, ParticipantId uint, reason string) error // 参加者を外し、再参加を禁止する（募集主のみ）
+	CreateInvite(ctx context.Context, invite *model.QuestInvite, UserId uint) error                         // 招待トークンの作成（募集主のみ）
+	GetInvites(ctx context.Context, invites *[]model.QuestInvite, UserId uint, QuestId uint) error
+	DeleteInvite(ctx context.Context, UserId uint, QuestId uint, token string) error
+	CheckInvite(ctx context.Context, QuestId uint, token string) error                               // 招待トークンが有効か確認する（使用回数は増やさない）
+	GetQuestsStamp(ctx context.Context, stamp *model.QuestStamp) error                               // クエスト一覧全体の更新状況を取得する
+	GetQuestStamp(ctx context.Context, stamp *model.QuestStamp, QuestId uint) error                  // 指定したクエストの更新状況を取得する
+	TransferQuests(ctx context.Context, FromUserId uint, ToUserId uint, QuestId uint) (int64, error) // 募集主を変更する（QuestIdが0なら全てのクエスト）
 }
 
 type questRepository struct {
@@ -32,66 +51,137 @@ func NewQuestRepository(db *gorm.DB) IQuestRepository {
 	return &questRepository{db}
 }
 
-func (qr *questRepository) GetAllQuestsFromDB(quests *[]model.Quest) error {
-	if err := qr.db.Preload("User").Preload("Participants.User").Order("created_at DESC").Find(quests).Error; err != nil {
-		// 募集主の情報 + 参加者の情報（全て）を取得。フィールド名を指定
+// 一覧表示用のクエリ（作成者名・参加者数・参加者名の一部・閲覧者の参加状態・タグを1回のクエリで集計する）
+func (qr *questRepository) summaries(ctx context.Context, viewerId uint) *gorm.DB {
+	return conn(ctx, qr.db).Table("quests").
+		Select(`quests.id, quests.title, quests.description, quests.category, quests.category_id, quests.custom_fields,
+			quests.max_participants, quests.deadline, quests.start_time, quests.end_time, quests.url,
+			quests.requires_approval, quests.visibility, quests.created_at, quests.updated_at, quests.user_id,
+			users.user_name AS user_name,
+			(SELECT COUNT(*) FROM quest_participants qp
+				WHERE qp.quest_id = quests.id AND qp.status = @approved) AS participant_count,
+			COALESCE((SELECT json_agg(preview.user_name ORDER BY preview.joined_at, preview.user_id) FROM (
+				SELECT u.user_name, qp.joined_at, qp.user_id FROM quest_participants qp JOIN users u ON u.id = qp.user_id
+				WHERE qp.quest_id = quests.id AND qp.status = @approved
+				ORDER BY qp.joined_at, qp.user_id LIMIT @limit) preview), '[]') AS participant_preview,
+			COALESCE((SELECT qp.status FROM quest_participants qp
+				WHERE qp.quest_id = quests.id AND qp.user_id = @viewer), '') AS join_status,
+			COALESCE((SELECT json_agg(t.name ORDER BY t.id) FROM quest_tags qt JOIN tags t ON t.id = qt.tag_id
+				WHERE qt.quest_id = quests.id), '[]') AS tag_names`,
+			map[string]interface{}{"approved": model.ParticipantApproved, "limit": model.ParticipantPreviewLimit, "viewer": viewerId}).
+		Joins("JOIN users ON users.id = quests.user_id")
+}
+
+func (qr *questRepository) GetAllQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, viewerId uint, filter model.QuestFilter) error {
+	query := qr.summaries(ctx, viewerId).Where("quests.visibility = ?", model.VisibilityPublic)
+	// タグで絞り込み（AND：全てのタグを持つ / OR：いずれかのタグを持つ）
+	if len(filter.Tags) > 0 {
+		tagged := conn(ctx, qr.db).Table("quest_tags").Select("quest_tags.quest_id").
+			Joins("JOIN tags ON tags.id = quest_tags.tag_id").
+			Where("tags.slug IN ?", filter.Tags)
+		if filter.MatchAll {
+			tagged = tagged.Group("quest_tags.quest_id").Having("COUNT(DISTINCT tags.id) = ?", len(filter.Tags))
+		}
+		query = query.Where("quests.id IN (?)", tagged)
+	}
+	if err := query.Order("quests.created_at DESC").Scan(quests).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (qr *questRepository) GetUserQuestsFromDB(quests *[]model.Quest, userId uint) error {
+func (qr *questRepository) GetUserQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error {
 	// クエスト一覧の中から、引数で渡されたuserIdと一致するクエスト一覧を取得する
-	// UserテーブルのUserIdを参照 / created_atでソート / クエスト一覧をquestsに格納
-	if err := qr.db.Joins("User").Where("user_id=?", userId).Preload("Participants.User").Order("start_time DESC").Find(quests).Error; err != nil {
+	if err := qr.summaries(ctx, userId).Where("quests.user_id = ?", userId).
+		Order("quests.start_time DESC").Scan(quests).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (qr *questRepository) GetJoinedQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error {
+	// 参加（申請）しているクエスト（参加禁止は除く）
+	joined := conn(ctx, qr.db).Model(&model.QuestParticipant{}).Select("quest_id").
+		Where("user_id = ? AND status <> ?", userId, model.ParticipantBanned)
+	if err := qr.summaries(ctx, userId).Where("quests.id IN (?)", joined).
+		Order("quests.start_time DESC").Scan(quests).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (qr *questRepository) GetPublicUserQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error {
+	if err := qr.summaries(ctx, 0).Where("quests.user_id = ? AND quests.visibility = ?", userId, model.VisibilityPublic).
+		Order("quests.start_time DESC").Scan(quests).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (qr *questRepository) GetJoinedQuestsFromDB(quests *[]model.Quest, userId uint) error {
-	// Participantsテーブルを結合｜ユーザーIDの一致するレコードを取得｜関連するエンティティを取得
-	if err := qr.db.Joins("JOIN quest_participants ON quests.id = quest_participants.quest_id").
-		Where("quest_participants.user_id = ?", userId).
-		Preload("User").
-		Preload("Participants.User").
-		Order("start_time DESC").
-		Find(quests).Error; err != nil {
+func (qr *questRepository) GetPublicJoinedQuestsFromDB(ctx context.Context, quests *[]model.QuestSummary, userId uint) error {
+	joined := conn(ctx, qr.db).Model(&model.QuestParticipant{}).Select("quest_id").
+		Where("user_id = ? AND status = ?", userId, model.ParticipantApproved)
+	if err := qr.summaries(ctx, 0).Where("quests.id IN (?) AND quests.visibility = ?", joined, model.VisibilityPublic).
+		Order("quests.start_time DESC").Scan(quests).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (qr *questRepository) GetQuestById(quest *model.Quest, userId uint, questId uint) error {
+func (qr *questRepository) GetQuestById(ctx context.Context, quest *model.Quest, userId uint, questId uint) error {
 	// 指定されたUserIdのクエスト一覧で、QuestIdが一致するクエストを取得して quest に格納
-	if err := qr.db.Joins("User").Where("user_id=?", userId).First(quest, questId).Error; err != nil {
+	if err := conn(ctx, qr.db).Joins("User").Preload("Tags").Where("user_id=?", userId).First(quest, questId).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (qr *questRepository) CreateQuest(quest *model.Quest) error {
-	if err := qr.db.Create(quest).Error; err != nil {
+func (qr *questRepository) GetQuestForView(ctx context.Context, quest *model.QuestSummary, viewerId uint, questId uint) error {
+	// 公開範囲の判定はusecaseで行う
+	result := qr.summaries(ctx, viewerId).Where("quests.id = ?", questId).Limit(1).Scan(quest)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (qr *questRepository) GetParticipants(ctx context.Context, participants *[]model.QuestParticipant, questId uint) error {
+	if err := conn(ctx, qr.db).Preload("User").Where("quest_id = ? AND status = ?", questId, model.ParticipantApproved).
+		Order("joined_at, user_id").Find(participants).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (qr *questRepository) UpdateQuest(quest *model.Quest, userId uint, questId uint) error {
-	result := qr.db.Model(quest).Clauses(clause.Returning{}).Where("id=? AND user_id=?", questId, userId).Updates(map[string]interface{}{
-		"id":               quest.ID,
-		"title":            quest.Title,
-		"description":      quest.Description,
-		"category":         quest.Category,
-		"max_participants": quest.MaxParticipants,
-		"deadline":         quest.Deadline,
-		"start_time":       quest.StartTime,
-		"end_time":         quest.EndTime,
-		"url":              quest.URL,
+func (qr *questRepository) CreateQuest(ctx context.Context, quest *model.Quest) error {
+	if err := conn(ctx, qr.db).Create(quest).Error; err != nil {
+		return translateError(err)
+	}
+	return nil
+}
+
+func (qr *questRepository) UpdateQuest(ctx context.Context, quest *model.Quest, userId uint, questId uint) error {
+	result := conn(ctx, qr.db).Model(quest).Omit(clause.Associations).Clauses(clause.Returning{}).Where("id=? AND user_id=?", questId, userId).Updates(map[string]interface{}{
+		"id":                quest.ID,
+		"title":             quest.Title,
+		"description":       quest.Description,
+		"category":          quest.Category,
+		"category_id":       quest.CategoryId,
+		"custom_fields":     quest.CustomFields,
+		"max_participants":  quest.MaxParticipants,
+		"deadline":          quest.Deadline,
+		"start_time":        quest.StartTime,
+		"end_time":          quest.EndTime,
+		"url":               quest.URL,
+		"requires_approval": quest.RequiresApproval,
+		"visibility":        quest.Visibility,
 		// "image":            quest.Image,
 	})
 	if result.Error != nil {
-		return result.Error
+		return translateError(result.Error)
 	}
 	if result.RowsAffected < 1 {
 		return fmt.Errorf("object does not exist") // エラーメッセージ
@@ -99,15 +189,16 @@ func (qr *questRepository) UpdateQuest(quest *model.Quest, userId uint, questId
 	return nil
 }
 
-func (qr *questRepository) DeleteQuest(userId uint, questId uint) error {
-	// まず、クエスト参加者テーブルの該当レコードを削除
-	participantDeleteResult := qr.db.Where("quest_id = ?", questId).Delete(&model.QuestParticipant{})
-	if participantDeleteResult.Error != nil {
-		return participantDeleteResult.Error
+func (qr *questRepository) ReplaceQuestTags(ctx context.Context, quest *model.Quest, tags []model.Tag) error {
+	if err := conn(ctx, qr.db).Model(quest).Association("Tags").Replace(tags); err != nil {
+		return err
 	}
+	return nil
+}
 
-	// 次に、クエストテーブルのレコードを削除
-	questDeleteResult := qr.db.Where("id=? AND user_id=?", questId, userId).Delete(&model.Quest{})
+func (qr *questRepository) DeleteQuest(ctx context.Context, userId uint, questId uint) error {
+	// 参加記録・タグ・招待トークンは外部キーのON DELETE CASCADEで削除される
+	questDeleteResult := conn(ctx, qr.db).Where("id=? AND user_id=?", questId, userId).Delete(&model.Quest{})
 	if questDeleteResult.Error != nil {
 		return questDeleteResult.Error
 	}
@@ -117,30 +208,270 @@ func (qr *questRepository) DeleteQuest(userId uint, questId uint) error {
 	return nil
 }
 
-func (qr *questRepository) JoinQuest(userId uint, questId uint) error {
-	now := time.Now()
-	jst := time.FixedZone("Asia/Tokyo", 9*60*60)
+func (qr *questRepository) JoinQuest(ctx context.Context, userId uint, questId uint, token string) error {
+	// 確認から登録までを1つのトランザクションで行い、定員の確認が同時に行われないようにする
+	return transaction(ctx, qr.db, func(ctx context.Context) error {
+		now := time.Now()
+		jst := time.FixedZone("Asia/Tokyo", 9*60*60)
+
+		// クエストの行をロックする（同じクエストへの参加は順番に処理される）
+		quest := model.Quest{}
+		if err := conn(ctx, qr.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "requires_approval", "max_participants", "visibility").First(&quest, questId).Error; err != nil {
+			return err
+		}
+
+		// 既にユーザーがクエストに参加（申請）しているか確認
+		existing := model.QuestParticipant{}
+		err := conn(ctx, qr.db).Where("user_id = ? AND quest_id = ?", userId, questId).First(&existing).Error
+		if err == nil {
+			if existing.Status == model.ParticipantRejected {
+				return fmt.Errorf("join request was rejected")
+			}
+			if existing.Status == model.ParticipantBanned {
+				return fmt.Errorf("you are banned from this quest")
+			}
+			return nil // 既に参加・申請している場合は何もせずに終了
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// 承認制のクエストなら承認待ちとして登録する
+		// 招待制のクエストは有効な招待トークンを1回分消費して参加する
+		if quest.Visibility == model.VisibilityInviteOnly {
+			if err := qr.useInvite(ctx, questId, token); err != nil {
+				return err
+			}
+		}
+		status := model.ParticipantApproved
+		if quest.RequiresApproval {
+			status = model.ParticipantPending
+		} else if err := qr.checkCapacity(ctx, quest); err != nil {
+			return err
+		}
+
+		participant := &model.QuestParticipant{
+			JoinedAt: now.In(jst), // 現在時刻を取得
+			UserId:   userId,
+			QuestId:  questId,
+			Status:   status,
+		}
+		// 一意制約で弾かれてもトランザクション全体が失敗しないよう、セーブポイントの中で登録する
+		err = transaction(ctx, qr.db, func(ctx context.Context) error {
+			return conn(ctx, qr.db).Create(participant).Error
+		})
+		if err != nil {
+			// 既に参加している場合と同じく何もしない
+			if err := translateError(err); !errors.Is(err, ErrAlreadyJoined) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (qr *questRepository) CancelQuest(ctx context.Context, userId uint, questId uint) error {
+	return transaction(ctx, qr.db, func(ctx context.Context) error {
+		// 参加確定・承認待ちのみ取り消せる（却下・参加禁止の記録は本人が消せないようにする）
+		result := conn(ctx, qr.db).Where("quest_id=? AND user_id=? AND status IN ?", questId, userId,
+			[]string{model.ParticipantApproved, model.ParticipantPending}).Delete(&model.QuestParticipant{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return fmt.Errorf("object does not exist")
+		}
+		// 参加取り消しを条件付きGETのLast-Modifiedに反映させるため、クエストの更新日時を進める
+		if err := conn(ctx, qr.db).Model(&model.Quest{}).Where("id = ?", questId).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func (qr *questRepository) GetJoinRequests(ctx context.Context, participants *[]model.QuestParticipant, userId uint, questId uint) error {
+	// 募集主本人のクエストか確認
+	if err := conn(ctx, qr.db).Where("id=? AND user_id=?", questId, userId).First(&model.Quest{}).Error; err != nil {
+		return err
+	}
+	if err := conn(ctx, qr.db).Preload("User").Where("quest_id = ? AND status = ?", questId, model.ParticipantPending).
+		Order("joined_at").Find(participants).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (qr *questRepository) DecideJoinRequest(ctx context.Context, userId uint, questId uint, participantId uint, status string, message string) error {
+	return transaction(ctx, qr.db, func(ctx context.Context) error {
+		// 募集主本人のクエストか確認
+		if err := conn(ctx, qr.db).Where("id=? AND user_id=?", questId, userId).First(&model.Quest{}).Error; err != nil {
+			return err
+		}
+		// 承認すると定員を超える場合はエラー
+		if status == model.ParticipantApproved {
+			quest := model.Quest{}
+			if err := conn(ctx, qr.db).Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select("id", "max_participants").First(&quest, questId).Error; err != nil {
+				return err
+			}
+			if err := qr.checkCapacity(ctx, quest); err != nil {
+				return err
+			}
+		}
+		// 承認待ちの申請だけを更新する（承認時は参加日時を承認した時刻にする）
+		updates := map[string]interface{}{
+			"status":  status,
+			"message": message,
+		}
+		if status == model.ParticipantApproved {
+			updates["joined_at"] = time.Now().In(time.FixedZone("Asia/Tokyo", 9*60*60))
+		}
+		result := conn(ctx, qr.db).Model(&model.QuestParticipant{}).
+			Where("quest_id = ? AND user_id = ? AND status = ?", questId, participantId, model.ParticipantPending).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return fmt.Errorf("object does not exist")
+		}
+		// 参加者一覧の変化を条件付きGETに反映させる
+		if err := conn(ctx, qr.db).Model(&model.Quest{}).Where("id = ?", questId).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
 
-	// 既にユーザーがクエストに参加しているか確認
+// 参加確定者が定員（0なら無制限）に達していればエラーを返す
+func (qr *questRepository) checkCapacity(ctx context.Context, quest model.Quest) error {
+	if quest.MaxParticipants == 0 {
+		return nil
+	}
 	var count int64
-	qr.db.Model(&model.QuestParticipant{}).Where("user_id = ? AND quest_id = ?", userId, questId).Count(&count)
-	if count > 0 {
-		return nil // 既に参加している場合は何もせずに終了
+	if err := conn(ctx, qr.db).Model(&model.QuestParticipant{}).
+		Where("quest_id = ? AND status = ?", quest.ID, model.ParticipantApproved).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= int64(quest.MaxParticipants) {
+		return ErrQuestFull
 	}
+	return nil
+}
+
+func (qr *questRepository) RemoveParticipant(ctx context.Context, userId uint, questId uint, participantId uint) error {
+	return transaction(ctx, qr.db, func(ctx context.Context) error {
+		// 募集主本人のクエストか確認
+		if err := conn(ctx, qr.db).Where("id=? AND user_id=?", questId, userId).First(&model.Quest{}).Error; err != nil {
+			return err
+		}
+		// 参加確定・承認待ちの記録だけを削除する（参加禁止の記録は残す）
+		result := conn(ctx, qr.db).Where("quest_id = ? AND user_id = ? AND status IN ?", questId, participantId,
+			[]string{model.ParticipantApproved, model.ParticipantPending}).Delete(&model.QuestParticipant{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return fmt.Errorf("object does not exist")
+		}
+		if err := conn(ctx, qr.db).Model(&model.Quest{}).Where("id = ?", questId).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func (qr *questRepository) BanParticipant(ctx context.Context, userId uint, questId uint, participantId uint, reason string) error {
+	return transaction(ctx, qr.db, func(ctx context.Context) error {
+		// 募集主本人のクエストか確認
+		if err := conn(ctx, qr.db).Where("id=? AND user_id=?", questId, userId).First(&model.Quest{}).Error; err != nil {
+			return err
+		}
+		if participantId == userId {
+			return fmt.Errorf("cannot ban yourself")
+		}
+		// 参加記録があれば参加禁止に変更し、なければ参加禁止の記録を作成する
+		result := conn(ctx, qr.db).Model(&model.QuestParticipant{}).Where("quest_id = ? AND user_id = ?", questId, participantId).
+			Updates(map[string]interface{}{"status": model.ParticipantBanned, "message": reason})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			participant := &model.QuestParticipant{
+				JoinedAt: time.Now().In(time.FixedZone("Asia/Tokyo", 9*60*60)),
+				UserId:   participantId,
+				QuestId:  questId,
+				Status:   model.ParticipantBanned,
+				Message:  reason,
+			}
+			if err := conn(ctx, qr.db).Create(participant).Error; err != nil {
+				return translateError(err)
+			}
+		}
+		if err := conn(ctx, qr.db).Model(&model.Quest{}).Where("id = ?", questId).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// 有効期限内・使用回数の上限内であれば、招待トークンの使用回数を1増やす
+func (qr *questRepository) useInvite(ctx context.Context, questId uint, token string) error {
+	if token == "" {
+		return fmt.Errorf("invite token is required")
+	}
+	result := conn(ctx, qr.db).Model(&model.QuestInvite{}).
+		Where("quest_id = ? AND token = ?", questId, token).
+		Where("expires_at <= to_timestamp(0) OR expires_at > ?", time.Now()).
+		Where("max_uses = 0 OR uses < max_uses").
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("invalid or expired invite token")
+	}
+	return nil
+}
 
-	participant := &model.QuestParticipant{
-		JoinedAt: now.In(jst), // 現在時刻を取得
-		UserId:   userId,
-		QuestId:  questId,
+func (qr *questRepository) CheckInvite(ctx context.Context, questId uint, token string) error {
+	if err := conn(ctx, qr.db).Where("quest_id = ? AND token = ?", questId, token).
+		Where("expires_at <= to_timestamp(0) OR expires_at > ?", time.Now()).
+		Where("max_uses = 0 OR uses < max_uses").
+		First(&model.QuestInvite{}).Error; err != nil {
+		return err
 	}
-	if err := qr.db.Create(participant).Error; err != nil {
+	return nil
+}
+
+func (qr *questRepository) CreateInvite(ctx context.Context, invite *model.QuestInvite, userId uint) error {
+	// 募集主本人のクエストか確認
+	if err := conn(ctx, qr.db).Where("id=? AND user_id=?", invite.QuestId, userId).First(&model.Quest{}).Error; err != nil {
 		return err
 	}
+	if err := conn(ctx, qr.db).Create(invite).Error; err != nil {
+		return translateError(err)
+	}
 	return nil
 }
 
-func (qr *questRepository) CancelQuest(userId uint, questId uint) error {
-	result := qr.db.Where("quest_id=? AND user_id=?", questId, userId).Delete(&model.QuestParticipant{})
+func (qr *questRepository) GetInvites(ctx context.Context, invites *[]model.QuestInvite, userId uint, questId uint) error {
+	if err := conn(ctx, qr.db).Where("id=? AND user_id=?", questId, userId).First(&model.Quest{}).Error; err != nil {
+		return err
+	}
+	if err := conn(ctx, qr.db).Where("quest_id = ?", questId).Order("created_at DESC").Find(invites).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (qr *questRepository) DeleteInvite(ctx context.Context, userId uint, questId uint, token string) error {
+	if err := conn(ctx, qr.db).Where("id=? AND user_id=?", questId, userId).First(&model.Quest{}).Error; err != nil {
+		return err
+	}
+	result := conn(ctx, qr.db).Where("quest_id = ? AND token = ?", questId, token).Delete(&model.QuestInvite{})
 	if result.Error != nil {
 		return result.Error
 	}
@@ -149,3 +480,76 @@ func (qr *questRepository) CancelQuest(userId uint, questId uint) error {
 	}
 	return nil
 }
+
+func (qr *questRepository) GetQuestsStamp(ctx context.Context, stamp *model.QuestStamp) error {
+	// クエストの最終更新日時と件数
+	if err := conn(ctx, qr.db).Model(&model.Quest{}).
+		Select("COALESCE(MAX(updated_at), to_timestamp(0)) AS updated_at, COUNT(*) AS quest_count").
+		Scan(stamp).Error; err != nil {
+		return err
+	}
+	// 参加者の最終参加日時と件数（参加取り消しは件数の変化で検出する）
+	if err := conn(ctx, qr.db).Model(&model.QuestParticipant{}).
+		Select("COALESCE(MAX(joined_at), to_timestamp(0)) AS joined_at, COUNT(*) AS participant_count").
+		Scan(stamp).Error; err != nil {
+		return err
+	}
+	// 一覧に名前が載る募集主・参加者のユーザー情報の最終更新日時
+	if err := conn(ctx, qr.db).Model(&model.User{}).
+		Where("id IN (SELECT user_id FROM quests UNION SELECT user_id FROM quest_participants)").
+		Select("COALESCE(MAX(updated_at), to_timestamp(0)) AS user_updated_at").
+		Scan(stamp).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (qr *questRepository) GetQuestStamp(ctx context.Context, stamp *model.QuestStamp, questId uint) error {
+	quest := model.Quest{}
+	if err := conn(ctx, qr.db).Select("id", "updated_at").First(&quest, questId).Error; err != nil {
+		return err
+	}
+	stamp.UpdatedAt = quest.UpdatedAt
+	stamp.QuestCount = 1
+	if err := conn(ctx, qr.db).Model(&model.QuestParticipant{}).Where("quest_id = ?", questId).
+		Select("COALESCE(MAX(joined_at), to_timestamp(0)) AS joined_at, COUNT(*) AS participant_count").
+		Scan(stamp).Error; err != nil {
+		return err
+	}
+	if err := conn(ctx, qr.db).Model(&model.User{}).
+		Where("id IN (SELECT user_id FROM quests WHERE id = ? UNION SELECT user_id FROM quest_participants WHERE quest_id = ?)", questId, questId).
+		Select("COALESCE(MAX(updated_at), to_timestamp(0)) AS user_updated_at").
+		Scan(stamp).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (qr *questRepository) TransferQuests(ctx context.Context, fromUserId uint, toUserId uint, questId uint) (int64, error) {
+	var transferred int64
+	err := transaction(ctx, qr.db, func(ctx context.Context) error {
+		tx := conn(ctx, qr.db)
+		target := func() *gorm.DB {
+			query := tx.Model(&model.Quest{}).Where("user_id = ?", fromUserId)
+			if questId != 0 {
+				query = query.Where("id = ?", questId)
+			}
+			return query
+		}
+		// 引き継ぎ先が参加者として登録されていれば、主催者になるので参加記録を外す
+		if err := tx.Where("user_id = ? AND quest_id IN (?)", toUserId, target().Select("id")).
+			Delete(&model.QuestParticipant{}).Error; err != nil {
+			return err
+		}
+		result := target().Updates(map[string]interface{}{"user_id": toUserId, "updated_at": time.Now()})
+		if result.Error != nil {
+			return translateError(result.Error)
+		}
+		if questId != 0 && result.RowsAffected < 1 {
+			return fmt.Errorf("object does not exist")
+		}
+		transferred = result.RowsAffected
+		return nil
+	})
+	return transferred, err
+}
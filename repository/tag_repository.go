@@ -0,0 +1,121 @@
+package repository
+
+/* データベース操作 */
+
+import (
+	"bulletin-board-rest-api/model"
+	"context"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type ITagRepository interface {
+	FindOrCreateTag(ctx context.Context, tag *model.Tag, slug string, name string) error // 別名も考慮してタグを取得し、なければ作成する
+	ResolveSlug(ctx context.Context, slug string) (string, error)                        // 別名なら統合先のslugを返す
+	SearchTags(ctx context.Context, tags *[]model.Tag, prefix string, limit int) error   // 名前・slug・別名の前方一致
+	GetPopularTags(ctx context.Context, tags *[]model.TagResponse, limit int) error      // 公開クエストでの使用数が多い順
+	CreateTagAlias(ctx context.Context, aliasSlug string, tagSlug string) error
+}
+
+type tagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) ITagRepository {
+	return &tagRepository{db}
+}
+
+func (tr *tagRepository) FindOrCreateTag(ctx context.Context, tag *model.Tag, slug string, name string) error {
+	resolved, err := tr.ResolveSlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+	// 同じslugのタグがなければ作成する
+	// 同時に作成されて一意制約で弾かれた場合は、セーブポイントまで戻して作成されたタグを取得する
+	err = transaction(ctx, tr.db, func(ctx context.Context) error {
+		return conn(ctx, tr.db).Where(model.Tag{Slug: resolved}).Attrs(model.Tag{Name: name}).FirstOrCreate(tag).Error
+	})
+	if errors.Is(translateError(err), ErrDuplicate) {
+		err = conn(ctx, tr.db).Where("slug = ?", resolved).First(tag).Error
+	}
+	return err
+}
+
+func (tr *tagRepository) ResolveSlug(ctx context.Context, slug string) (string, error) {
+	alias := model.TagAlias{}
+	err := conn(ctx, tr.db).Preload("Tag").Where("slug = ?", slug).First(&alias).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return slug, nil // 別名でなければそのまま
+	}
+	if err != nil {
+		return "", err
+	}
+	return alias.Tag.Slug, nil
+}
+
+func (tr *tagRepository) SearchTags(ctx context.Context, tags *[]model.Tag, prefix string, limit int) error {
+	pattern := escapeLike(prefix) + "%"
+	if err := conn(ctx, tr.db).Where(`slug LIKE ? ESCAPE '\' OR name LIKE ? ESCAPE '\' OR id IN (?)`, pattern, pattern,
+		conn(ctx, tr.db).Model(&model.TagAlias{}).Select("tag_id").Where(`slug LIKE ? ESCAPE '\'`, pattern)).
+		Order("slug").Limit(limit).Find(tags).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// LIKEのパターンで特別な意味を持つ文字（\ % _）をエスケープする
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (tr *tagRepository) GetPopularTags(ctx context.Context, tags *[]model.TagResponse, limit int) error {
+	if err := conn(ctx, tr.db).Model(&model.Tag{}).
+		Select("tags.name, tags.slug, COUNT(quests.id) AS quest_count").
+		Joins("JOIN quest_tags ON quest_tags.tag_id = tags.id").
+		Joins("JOIN quests ON quests.id = quest_tags.quest_id AND quests.visibility = ?", model.VisibilityPublic).
+		Group("tags.id").
+		Order("quest_count DESC, tags.slug").
+		Limit(limit).
+		Scan(tags).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (tr *tagRepository) CreateTagAlias(ctx context.Context, aliasSlug string, tagSlug string) error {
+	// 統合先のタグ（別名の場合はさらにその統合先）を取得
+	resolved, err := tr.ResolveSlug(ctx, tagSlug)
+	if err != nil {
+		return err
+	}
+	tag := model.Tag{}
+	if err := conn(ctx, tr.db).Where("slug = ?", resolved).First(&tag).Error; err != nil {
+		return err
+	}
+	return transaction(ctx, tr.db, func(ctx context.Context) error {
+		tx := conn(ctx, tr.db)
+		// 別名と同じslugのタグが既にあれば、そのタグのクエストを統合先に付け替えて削除する
+		old := model.Tag{}
+		err := tx.Where("slug = ?", aliasSlug).First(&old).Error
+		if err == nil && old.ID != tag.ID {
+			if err := tx.Exec(`INSERT INTO quest_tags (quest_id, tag_id)
+				SELECT quest_id, ? FROM quest_tags WHERE tag_id = ? ON CONFLICT DO NOTHING`, tag.ID, old.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM quest_tags WHERE tag_id = ?", old.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.TagAlias{}).Where("tag_id = ?", old.ID).Update("tag_id", tag.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&old).Error; err != nil {
+				return err
+			}
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return tx.Create(&model.TagAlias{Slug: aliasSlug, TagId: tag.ID}).Error
+	})
+}
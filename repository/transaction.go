@@ -0,0 +1,89 @@
+package repository
+
+/*
+複数のリポジトリにまたがる処理を1つのトランザクションで実行する（Unit of Work）
+ ・トランザクションはcontextに入れて渡し、各リポジトリは conn(ctx, db) でそれを使う
+ ・トランザクションの中でさらにDoを呼ぶとセーブポイントになり、失敗してもその中の変更だけが取り消される
+ ・デッドロックの場合は、一番外側のトランザクションを最初からやり直す
+   （分離レベルはREAD COMMITTEDのままなので、シリアライズ失敗(40001)は起きない）
+*/
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	maxTxRetries     = 3                     // やり直す回数の上限
+	txRetryBaseDelay = 20 * time.Millisecond // 1回目のやり直しまでの待ち時間（毎回2倍にする）
+)
+
+// PostgreSQLのエラーコード（やり直せば成功する可能性があるもの）
+const pgDeadlockDetected = "40P01"
+
+type ITransactionManager interface {
+	// fnを1つのトランザクションで実行し、エラーを返せばロールバックする
+	// やり直しでfnが複数回呼ばれることがあるので、fnの中でメール送信などの外部への副作用を起こさないこと
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactionManager struct {
+	db *gorm.DB
+}
+
+func NewTransactionManager(db *gorm.DB) ITransactionManager {
+	return &transactionManager{db}
+}
+
+type txKey struct{}
+
+func (tm *transactionManager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	// 既にトランザクションの中ならセーブポイントを作る（やり直しは外側のトランザクションに任せる）
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx).Transaction(func(sp *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, sp))
+		})
+	}
+	delay := txRetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := tm.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, tx))
+		})
+		if err == nil || !isRetryable(err) || attempt >= maxTxRetries {
+			return err
+		}
+		// 同時に実行されている処理とぶつからないよう、少しずらして待つ
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay + time.Duration(rand.Int63n(int64(delay)))):
+		}
+		delay *= 2
+	}
+}
+
+// contextにトランザクションがあればそれを、なければ通常の接続を返す
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// リポジトリの中で複数のクエリをまとめて実行する（呼び出し元がトランザクション中ならその一部になる）
+func transaction(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) error {
+	return NewTransactionManager(db).Do(ctx, fn)
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == pgDeadlockDetected
+}
@@ -4,15 +4,27 @@ package repository
 
 import (
 	"bulletin-board-rest-api/model"
+	"context"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type IUserRepository interface {
-	GetUserByEmail(user *model.User, email string) error //引数のuserにemailをもつユーザーを格納｜返り値 エラーを返すときに使う
-	CreateUser(user *model.User) error                   //引数のuserをDBに保存
-	GetUserByID(user *model.User, userId uint) error
-	UpdateUserName(userId uint, newUserName string) error
+	GetUserByEmail(ctx context.Context, user *model.User, email string) error //引数のuserにemailをもつユーザーを格納｜返り値 エラーを返すときに使う
+	CreateUser(ctx context.Context, user *model.User) error                   //引数のuserをDBに保存
+	GetUserByID(ctx context.Context, user *model.User, userId uint) error
+	UpdateUserName(ctx context.Context, userId uint, newUserName string) error
+	GetUserByUserName(ctx context.Context, user *model.User, userName string) error
+	UpdateProfile(ctx context.Context, userId uint, profile model.UpdateProfileRequest) error
+	DeleteAccount(ctx context.Context, userId uint, transferTo uint) error // transferToが0なら作成したクエストを削除する
+	UpdatePassword(ctx context.Context, userId uint, hash string) error    // 発行済みのJWTも無効にする
+	SetPendingEmail(ctx context.Context, userId uint, email string, tokenHash string, expiresAt time.Time) error
+	GetUserByEmailToken(ctx context.Context, user *model.User, tokenHash string) error
+	ConfirmPendingEmail(ctx context.Context, userId uint) error // 確認待ちのメールアドレスに切り替える
+	SetAdmin(ctx context.Context, userId uint, isAdmin bool) error
+	SetSuspended(ctx context.Context, userId uint, suspended bool) error // 利用停止にする場合は発行済みのJWTも無効にする
 }
 
 type userRepository struct {
@@ -23,11 +35,11 @@ func NewUserRepository(db *gorm.DB) IUserRepository {
 	return &userRepository{db}
 }
 
-func (ur *userRepository) GetUserByEmail(user *model.User, email string) error {
+func (ur *userRepository) GetUserByEmail(ctx context.Context, user *model.User, email string) error {
 	// 1. データベースから指定された email に一致するユーザーを取得する。
 	// 2. もしデータベース操作中にエラーが発生した場合、そのエラーを err 変数に代入する。
 	// 3. err 変数が nil でない場合は、エラーを呼び出し元に返す。
-	err := ur.db.Where("email = ?", email).First(user).Error //DBから指定されたemailに一致するユーザーを取得
+	err := conn(ctx, ur.db).Where("email = ?", email).First(user).Error //DBから指定されたemailに一致するユーザーを取得
 	if err != nil {
 		return err //そのままエラー文を返す
 	} else {
@@ -35,8 +47,8 @@ func (ur *userRepository) GetUserByEmail(user *model.User, email string) error {
 	}
 }
 
-func (ur *userRepository) CreateUser(user *model.User) error {
-	err := ur.db.Create(user).Error //引数のuserをDBに保存
+func (ur *userRepository) CreateUser(ctx context.Context, user *model.User) error {
+	err := conn(ctx, ur.db).Create(user).Error //引数のuserをDBに保存
 	if err != nil {
 		return err
 	} else {
@@ -45,8 +57,8 @@ func (ur *userRepository) CreateUser(user *model.User) error {
 }
 
 // ユーザーIDを指定してユーザー情報を取得
-func (ur *userRepository) GetUserByID(user *model.User, userId uint) error {
-	err := ur.db.First(user, userId).Error // ユーザーIDを指定してユーザー情報を取得
+func (ur *userRepository) GetUserByID(ctx context.Context, user *model.User, userId uint) error {
+	err := conn(ctx, ur.db).First(user, userId).Error // ユーザーIDを指定してユーザー情報を取得
 	if err != nil {
 		return err
 	} else {
@@ -54,11 +66,182 @@ func (ur *userRepository) GetUserByID(user *model.User, userId uint) error {
 	}
 }
 
-func (ur *userRepository) UpdateUserName(userId uint, newUserName string) error {
-	err := ur.db.Model(&model.User{}).Where("id = ?", userId).Update("user_name", newUserName).Error
+func (ur *userRepository) UpdateUserName(ctx context.Context, userId uint, newUserName string) error {
+	err := conn(ctx, ur.db).Model(&model.User{}).Where("id = ?", userId).Update("user_name", newUserName).Error
 	if err != nil {
 		return err
 	} else {
 		return nil
 	}
 }
+
+func (ur *userRepository) GetUserByUserName(ctx context.Context, user *model.User, userName string) error {
+	err := conn(ctx, ur.db).Where("user_name = ?", userName).First(user).Error
+	if err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
+func (ur *userRepository) UpdateProfile(ctx context.Context, userId uint, profile model.UpdateProfileRequest) error {
+	err := conn(ctx, ur.db).Model(&model.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"display_name": profile.DisplayName,
+		"bio":          profile.Bio,
+		"faculty":      profile.Faculty,
+		"grade":        profile.Grade,
+		"avatar":       profile.Avatar,
+		"links":        profile.Links,
+	}).Error
+	if err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
+/*
+* 退会処理を1つのトランザクションで行う
+* 1. 作成したクエストを引き継ぐ or 削除する
+* 2. これから開催されるクエストへの参加を取り消す（開催済みの参加記録は残す）
+* 3. お知らせを削除する
+* 4. 個人情報を消して匿名のユーザーにする（過去の参加記録の表示のために行は残す）
+ */
+func (ur *userRepository) DeleteAccount(ctx context.Context, userId uint, transferTo uint) error {
+	return transaction(ctx, ur.db, func(ctx context.Context) error {
+		tx := conn(ctx, ur.db)
+		ownQuests := tx.Model(&model.Quest{}).Select("id").Where("user_id = ?", userId)
+		if transferTo != 0 {
+			// 引き継ぎ先が参加者として登録されていれば、主催者になるので参加記録を外す
+			if err := tx.Where("user_id = ? AND quest_id IN (?)", transferTo, ownQuests).
+				Delete(&model.QuestParticipant{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Quest{}).Where("user_id = ?", userId).
+				Update("user_id", transferTo).Error; err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Where("quest_id IN (?)", ownQuests).Delete(&model.QuestParticipant{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", userId).Delete(&model.Quest{}).Error; err != nil {
+				return err
+			}
+		}
+
+		// 開始日時が未来（または未設定）のクエストへの参加を取り消す
+		future := tx.Model(&model.Quest{}).Select("id").
+			Where("start_time > ? OR start_time <= to_timestamp(0)", time.Now())
+		if err := tx.Where("user_id = ? AND quest_id IN (?)", userId, future).
+			Delete(&model.QuestParticipant{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userId).Delete(&model.Notification{}).Error; err != nil {
+			return err
+		}
+
+		// メールアドレス・ユーザー名は一意制約があるのでIDを使ってダミーの値にする
+		result := tx.Model(&model.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+			"email":         fmt.Sprintf("deleted-%d@invalid", userId),
+			"password":      "", // ログインできなくする
+			"user_name":     fmt.Sprintf("退会済み%d", userId),
+			"is_admin":      false,
+			"display_name":  "",
+			"bio":           "",
+			"faculty":       "",
+			"grade":         0,
+			"avatar":        nil,
+			"links":         model.StringList{},
+			"token_version": gorm.Expr("token_version + 1"), // 発行済みのJWTを無効にする
+			// 確認待ちのメールアドレスも消し、送信済みの確認リンクを使えなくする
+			"pending_email":          "",
+			"email_token":            "",
+			"email_token_expires_at": time.Time{},
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return fmt.Errorf("object does not exist")
+		}
+		return nil
+	})
+}
+
+func (ur *userRepository) UpdatePassword(ctx context.Context, userId uint, hash string) error {
+	err := conn(ctx, ur.db).Model(&model.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"password":      hash,
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error
+	if err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
+func (ur *userRepository) SetPendingEmail(ctx context.Context, userId uint, email string, tokenHash string, expiresAt time.Time) error {
+	err := conn(ctx, ur.db).Model(&model.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"pending_email":          email,
+		"email_token":            tokenHash,
+		"email_token_expires_at": expiresAt,
+	}).Error
+	if err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
+func (ur *userRepository) GetUserByEmailToken(ctx context.Context, user *model.User, tokenHash string) error {
+	err := conn(ctx, ur.db).Where("email_token = ? AND email_token_expires_at > ?", tokenHash, time.Now()).First(user).Error
+	if err != nil {
+		return err
+	} else {
+		return nil
+	}
+}
+
+func (ur *userRepository) ConfirmPendingEmail(ctx context.Context, userId uint) error {
+	result := conn(ctx, ur.db).Model(&model.User{}).Where("id = ? AND pending_email <> ''", userId).Updates(map[string]interface{}{
+		"email":                  gorm.Expr("pending_email"),
+		"pending_email":          "",
+		"email_token":            "",
+		"email_token_expires_at": time.Time{},
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (ur *userRepository) SetAdmin(ctx context.Context, userId uint, isAdmin bool) error {
+	result := conn(ctx, ur.db).Model(&model.User{}).Where("id = ?", userId).Update("is_admin", isAdmin)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
+
+func (ur *userRepository) SetSuspended(ctx context.Context, userId uint, suspended bool) error {
+	updates := map[string]interface{}{"suspended": suspended}
+	if suspended {
+		updates["token_version"] = gorm.Expr("token_version + 1")
+	}
+	result := conn(ctx, ur.db).Model(&model.User{}).Where("id = ?", userId).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return fmt.Errorf("object does not exist")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package router
+
+/* ルーター全体で使うミドルウェア */
+
+import (
+	"bulletin-board-rest-api/logger"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// クライアントから受け取るX-Request-IDとして認める形式
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// X-Request-IDを受け取る（なければ作成する）して、contextとレスポンスヘッダーにセットする
+func requestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			id := c.Request().Header.Get(echo.HeaderXRequestID)
+			if !validRequestID.MatchString(id) {
+				b := make([]byte, 16)
+				rand.Read(b)
+				id = hex.EncodeToString(b)
+			}
+			req := c.Request()
+			c.SetRequest(req.WithContext(logger.WithRequestID(req.Context(), id)))
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			return next(c)
+		}
+	}
+}
+
+// リクエストごとにアクセスログを出力する（ルートのテンプレート・ステータス・処理時間・ユーザーID）
+func accessLog(l *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				c.Error(err) // ステータスを確定させるためにここでエラーレスポンスを書き込む
+			}
+
+			attrs := []any{
+				"method", c.Request().Method,
+				"path", c.Request().URL.Path,
+				"route", c.Path(),
+				"status", c.Response().Status,
+				"latency_ms", time.Since(start).Milliseconds(),
+				"ip", c.RealIP(),
+			}
+			// ユーザーIDはRequireActiveSessionがcontextに入れ、ロガーが付ける（ここで足すとキーが重複する）
+			ctx := c.Request().Context()
+			switch {
+			case c.Response().Status >= 500:
+				l.ErrorContext(ctx, "request", attrs...)
+			case c.Response().Status >= 400:
+				l.WarnContext(ctx, "request", attrs...)
+			default:
+				l.InfoContext(ctx, "request", attrs...)
+			}
+			return nil
+		}
+	}
+}
+
+// ルートごとの処理時間の上限をcontextに設定するミドルウェア
+// 上限を超えたり、クライアントが切断したりするとcontextがキャンセルされ、実行中のSQLも中断される
+func deadline(def time.Duration, routes map[string]time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			d, ok := routes[c.Request().Method+" "+c.Path()]
+			if !ok {
+				d = def
+			}
+			if d <= 0 {
+				return next(c)
+			}
+			ctx, cancel := context.WithTimeout(c.Request().Context(), d)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
@@ -8,63 +8,178 @@ package router
 */
 
 import (
+	"bulletin-board-rest-api/config"
 	"bulletin-board-rest-api/controller"
+	"bulletin-board-rest-api/health"
+	"bulletin-board-rest-api/metrics"
+	"bulletin-board-rest-api/ratelimit"
+	"crypto/subtle"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
+	"time"
 
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-func NewRouter(uc controller.IUserController, qc controller.IQuestController) *echo.Echo {
+func NewRouter(cfg *config.Config, uc controller.IUserController, qc controller.IQuestController, tc controller.ITagController, cc controller.ICategoryController, rl ratelimit.ILimiter, hc health.IChecker, l *slog.Logger) *echo.Echo {
 	e := echo.New()
+	e.HideBanner = true
+	e.IPExtractor = ipExtractor(cfg.TrustedProxies) // レート制限とアクセスログで使うクライアントのIPアドレス
 
-	e.GET("/", func(c echo.Context) error {
-		return c.String(http.StatusOK, "Hello, World!")
-	})
+	//* リクエストIDとアクセスログのミドルウェアの設定（他のミドルウェアより先に実行する）
+	e.Use(requestID(), accessLog(l), metrics.Middleware())
+	e.Use(deadline(cfg.RequestTimeout, cfg.RouteTimeouts)) // ルートごとの処理時間の上限
+
+	//* メトリクス（METRICS_ADDRを指定した場合は別のアドレスで公開するのでここでは登録しない）
+	if cfg.MetricsAddr == "" && cfg.MetricsToken != "" {
+		e.GET("/metrics", echo.WrapHandler(metrics.Handler()), metricsAuth(cfg.MetricsToken))
+	}
+
+	//* ヘルスチェック（オーケストレーター用）
+	e.GET("/healthz", hc.Liveness)
+	e.GET("/readyz", hc.Readiness)
 
 	//* CORSのミドルウェアの設定
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"http://localhost:3000", "https://localhost:3000", os.Getenv("FE_URL")}, // フロントエンドのURLを許可
+		AllowOrigins: []string{"http://localhost:3000", "https://localhost:3000", cfg.FEURL}, // フロントエンドのURLを許可
 		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept,
-			echo.HeaderAccessControlAllowHeaders, echo.HeaderXCSRFToken, "Authorization"},
-		AllowMethods:     []string{"GET", "PUT", "POST", "DELETE"},
+			echo.HeaderAccessControlAllowHeaders, echo.HeaderXCSRFToken, "Authorization",
+			"If-None-Match", echo.HeaderIfModifiedSince, echo.HeaderXRequestID},
+		ExposeHeaders: []string{"ETag", echo.HeaderLastModified, // 条件付きGETのためにフロントエンドから読めるようにする
+			echo.HeaderRetryAfter, "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", echo.HeaderXRequestID},
+		AllowMethods:     []string{"GET", "PUT", "PATCH", "POST", "DELETE"},
 		AllowCredentials: true,
 	}))
 
 	//* ログイン関係のエンドポイントの設定
-	e.POST("/signup", uc.SignUp)
-	e.POST("/login", uc.LogIn)
+	//* 総当たり対策としてIPアドレス・アカウントごとに回数を制限する
+	e.POST("/signup", uc.SignUp, rl.PerIP("signup", 10, time.Hour), rl.PerAccount("signup", 5, time.Hour))
+	e.POST("/login", uc.LogIn, rl.PerIP("login", 30, 15*time.Minute), rl.PerAccount("login", 10, 15*time.Minute), rl.Lockout())
 	e.POST("/logout", uc.LogOut)
+	e.POST("/verify-email", uc.VerifyEmail) // メールアドレス変更の確認（メールのリンクから）
 
 	//* ユーザー関係のエンドポイントの設定
 	u := e.Group("/users")
 	u.Use(echojwt.WithConfig(echojwt.Config{
-		SigningKey:  []byte(os.Getenv("SECRET")),
+		SigningKey:  []byte(cfg.Secret),
 		TokenLookup: "header:Authorization", // headerからjwtトークンを取得
-	}))
+	}), uc.RequireActiveSession) // 無効化されたトークンを拒否
 	u.GET("/userName", uc.GetUserName)
 	u.GET("/userInfo", uc.GetUserInfo)
 	u.PUT("/userName", uc.UpdateUserName)
+	u.GET("/notifications", uc.GetNotifications)
+	u.PUT("/notifications/read", uc.MarkNotificationsAsRead) // お知らせを全て既読にする
+	u.PUT("/profile", uc.UpdateProfile)
+	u.DELETE("/me", uc.DeleteAccount)                          // 退会
+	u.PUT("/me/password", uc.ChangePassword)                   // パスワード変更
+	u.PUT("/me/email", uc.ChangeEmail)                         // メールアドレス変更（確認メールを送る）
+	u.GET("/:userId", uc.GetPublicProfile)                     // 公開プロフィール
+	u.GET("/by-name/:userName", uc.GetPublicProfileByUserName) // 公開プロフィール（ユーザー名で指定）
 
 	//* ミドルウェアの設定
 	q := e.Group("/quests")                  // クエスト関係のエンドポイントのグループ化
 	q.Use(echojwt.WithConfig(echojwt.Config{ //エンドポイントにミドルウェアの追加
-		SigningKey:  []byte(os.Getenv("SECRET")), // 環境変数からシークレットキーを取得
-		TokenLookup: "header:Authorization",      // headerからjwtトークンを取得
-	}))
+		SigningKey:  []byte(cfg.Secret),     // 設定からシークレットキーを取得
+		TokenLookup: "header:Authorization", // headerからjwtトークンを取得
+	}), uc.RequireActiveSession)
 
 	//* クエスト関係のエンドポイントの設定
-	q.GET("", qc.GetAllQuests)
-	q.GET("/:questId", qc.GetQuestById)
+	//* 一覧・詳細はETagで再検証させる（no-cache = 毎回サーバーに確認）
+	q.GET("", qc.GetAllQuests, cacheControl("private, no-cache"))
+	q.GET("/:questId", qc.GetQuestById, cacheControl("private, no-cache"))
 	q.POST("", qc.CreateQuest)
 	q.PUT("/:questId", qc.UpdateQuest)
+	q.PATCH("/:questId", qc.PatchQuest) // 送られた項目だけを更新
 	q.DELETE("/:questId", qc.DeleteQuest)
 
-	q.POST("/join/:questId", qc.JoinQuest) // クエストの参加
+	q.GET("/view/:questId", qc.GetQuestForView, cacheControl("private, no-store"))         // 公開範囲に応じたクエストの詳細
+	q.GET("/participants/:questId", qc.GetParticipants, cacheControl("private, no-store")) // 参加確定者の全員
+	q.POST("/join/:questId", qc.JoinQuest)                                                 // クエストの参加（招待制は ?token= が必要）
 	q.DELETE("/cancel/:questId", qc.CancelQuest)
-	q.GET("/created", qc.GetUserQuests)  // ユーザーが作成したクエスト一覧
-	q.GET("/joined", qc.GetJoinedQuests) // ユーザーが参加したクエスト一覧
+
+	//* 承認制クエストの参加申請（募集主のみ）
+	q.GET("/requests/:questId", qc.GetJoinRequests)
+	q.POST("/approve/:questId/:userId", qc.ApproveJoinRequest)
+	q.POST("/reject/:questId/:userId", qc.RejectJoinRequest)
+
+	//* 参加者の管理（募集主のみ）
+	q.POST("/remove/:questId/:userId", qc.RemoveParticipant)
+	q.POST("/ban/:questId/:userId", qc.BanParticipant)
+
+	//* 招待制クエストの招待トークン（募集主のみ）
+	q.GET("/invites/:questId", qc.GetInvites)
+	q.POST("/invites/:questId", qc.CreateInvite)
+	q.DELETE("/invites/:questId/:token", qc.DeleteInvite)
+
+	q.GET("/created", qc.GetUserQuests, cacheControl("private, no-store"))  // ユーザーが作成したクエスト一覧
+	q.GET("/joined", qc.GetJoinedQuests, cacheControl("private, no-store")) // ユーザーが参加したクエスト一覧
+
+	//* タグ関係のエンドポイントの設定
+	t := e.Group("/tags")
+	t.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(cfg.Secret),
+		TokenLookup: "header:Authorization",
+	}), uc.RequireActiveSession)
+	t.GET("/autocomplete", tc.AutocompleteTags) // タグ名の入力補完
+	t.GET("/popular", tc.GetPopularTags)        // 人気のタグ
+
+	//* カテゴリ一覧（クエスト作成画面用）
+	ca := e.Group("/categories")
+	ca.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(cfg.Secret),
+		TokenLookup: "header:Authorization",
+	}), uc.RequireActiveSession)
+	ca.GET("", cc.GetAllCategories)
+
+	//* 管理者用のエンドポイントの設定
+	a := e.Group("/admin")
+	a.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey:  []byte(cfg.Secret),
+		TokenLookup: "header:Authorization",
+	}), uc.RequireActiveSession, uc.RequireAdmin) // JWTの検証の後に管理者かどうかを確認
+	a.POST("/categories", cc.CreateCategory)
+	a.PUT("/categories/:categoryId", cc.UpdateCategory)
+	a.DELETE("/categories/:categoryId", cc.DeleteCategory)
+	a.POST("/tags/aliases", tc.CreateTagAlias) // 表記ゆれの統合
 	return e
 }
+
+// /metrics をトークン（Authorization: Bearer <METRICS_TOKEN>）を知っている人だけに公開するミドルウェア
+func metricsAuth(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			auth := c.Request().Header.Get(echo.HeaderAuthorization)
+			if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) != 1 {
+				return c.NoContent(http.StatusUnauthorized)
+			}
+			return next(c)
+		}
+	}
+}
+
+// ルートごとのCache-Controlヘッダーを設定するミドルウェア
+func cacheControl(policy string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Response().Header().Set(echo.HeaderCacheControl, policy)
+			return next(c)
+		}
+	}
+}
+
+// クライアントのIPアドレスの取り出し方
+// プロキシを指定しなければX-Forwarded-For・X-Real-IPは無視する（偽装してレート制限をすり抜けられるため）
+func ipExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	// echoのデフォルトではプライベートアドレスなども信頼するので、指定したものだけにする
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, ipNet := range trustedProxies {
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
@@ -0,0 +1,521 @@
+package router
+
+import (
+	"bulletin-board-rest-api/config"
+	"bulletin-board-rest-api/controller"
+	"bulletin-board-rest-api/mailer"
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/ratelimit"
+	"bulletin-board-rest-api/repository"
+	"bulletin-board-rest-api/repository/memory"
+	"bulletin-board-rest-api/usecase"
+	"bulletin-board-rest-api/validator"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "password"
+
+type fakeChecker struct{}
+
+func (fakeChecker) Liveness(c echo.Context) error  { return c.NoContent(http.StatusOK) }
+func (fakeChecker) Readiness(c echo.Context) error { return c.NoContent(http.StatusOK) }
+func (fakeChecker) SetShuttingDown()               {}
+
+// メモリ上のリポジトリでルーターを組み立てる（タグ・カテゴリのリポジトリは使わない）
+type testServer struct {
+	e  *echo.Echo
+	ur repository.IUserRepository
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWithConfig(t, &config.Config{Secret: "test-secret-0123456789abcdef0123456789", FEURL: "http://localhost:3000"})
+}
+
+func newTestServerWithConfig(t *testing.T, cfg *config.Config) *testServer {
+	t.Helper()
+	s := memory.NewStore()
+	ur, qr, nr := memory.NewUserRepository(s), memory.NewQuestRepository(s), memory.NewNotificationRepository(s)
+	tm := memory.NewTransactionManager(s)
+	uu := usecase.NewUserUsecase(ur, qr, nr, validator.NewUserValidator(), mailer.NewMailer(config.SMTPConfig{}), cfg, tm)
+	qu := usecase.NewQuestUsecase(qr, ur, nr, nil, nil, validator.NewQuestValidator(), tm)
+	rl := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.LockoutConfig{
+		MaxFailures: 5, BaseLockout: time.Minute, MaxLockout: time.Hour, FailWindow: time.Hour,
+	})
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e := NewRouter(cfg,
+		controller.NewUserController(uu),
+		controller.NewQuestController(qu),
+		controller.NewTagController(usecase.NewTagUsecase(nil)),
+		controller.NewCategoryController(usecase.NewCategoryUsecase(nil, validator.NewCategoryValidator())),
+		rl, fakeChecker{}, l)
+	return &testServer{e, ur}
+}
+
+// パスワードがtestPasswordのユーザーを作成する
+func (ts *testServer) createUser(t *testing.T, userName string, isAdmin bool) model.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := model.User{Email: userName + "@example.com", Password: string(hash), UserName: userName, IsAdmin: isAdmin}
+	if err := ts.ur.CreateUser(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func (ts *testServer) do(t *testing.T, method string, path string, token string, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, token) // TokenLookupに接頭辞がないのでJWTをそのまま送る
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	ts.e.ServeHTTP(rec, req)
+	return rec
+}
+
+func (ts *testServer) login(t *testing.T, user model.User) string {
+	t.Helper()
+	rec := ts.do(t, http.MethodPost, "/login", "", fmt.Sprintf(`{"email":%q,"password":%q}`, user.Email, testPassword))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /login = %d %s", rec.Code, rec.Body)
+	}
+	res := map[string]string{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res["token"]
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.createUser(t, "taro", false)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"正しいパスワード", fmt.Sprintf(`{"email":%q,"password":%q}`, user.Email, testPassword), http.StatusOK},
+		{"パスワードが違う", fmt.Sprintf(`{"email":%q,"password":"wrong-password"}`, user.Email), http.StatusUnauthorized},
+		{"登録されていないメールアドレス", `{"email":"nobody@example.com","password":"password"}`, http.StatusUnauthorized},
+		{"JSONが不正", `{"email":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(t, http.MethodPost, "/login", "", tt.body)
+			if rec.Code != tt.wantCode {
+				t.Errorf("POST /login = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
+
+// アカウントロックはパスワードの誤りだけを数え、入力の不備やサーバー側のエラーでは数えない
+func TestLogin_Lockout(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.createUser(t, "taro", false)
+	wrong := fmt.Sprintf(`{"email":%q,"password":"wrong-password"}`, user.Email)
+
+	steps := []struct {
+		body     string
+		wantCode int
+	}{
+		{wrong, http.StatusUnauthorized},
+		{wrong, http.StatusUnauthorized},
+		{wrong, http.StatusUnauthorized},
+		{wrong, http.StatusUnauthorized},
+		{fmt.Sprintf(`{"email":%q,"password":1}`, user.Email), http.StatusBadRequest},
+		{fmt.Sprintf(`{"email":%q,"password":1}`, user.Email), http.StatusBadRequest},
+		{fmt.Sprintf(`{"email":%q,"password":"abc"}`, user.Email), http.StatusInternalServerError},
+		{fmt.Sprintf(`{"email":%q,"password":"abc"}`, user.Email), http.StatusInternalServerError},
+		{fmt.Sprintf(`{"email":%q,"password":%q}`, user.Email, testPassword), http.StatusOK},
+		// 成功で失敗回数が消えるので、続けて間違えてもすぐにはロックされない
+		{wrong, http.StatusUnauthorized},
+	}
+	for i, step := range steps {
+		rec := ts.do(t, http.MethodPost, "/login", "", step.body)
+		if rec.Code != step.wantCode {
+			t.Fatalf("%d回目の POST /login = %d, want %d (%s)", i+1, rec.Code, step.wantCode, rec.Body)
+		}
+	}
+}
+
+// ロック中の429にもRetry-AfterとRateLimit-*ヘッダーを付ける
+func TestLogin_LockedHeaders(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.createUser(t, "taro", false)
+	wrong := fmt.Sprintf(`{"email":%q,"password":"wrong-password"}`, user.Email)
+	for i := 0; i < 5; i++ {
+		if rec := ts.do(t, http.MethodPost, "/login", "", wrong); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%d回目の POST /login = %d, want %d", i+1, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	rec := ts.do(t, http.MethodPost, "/login", "", fmt.Sprintf(`{"email":%q,"password":%q}`, user.Email, testPassword))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("ロック中の POST /login = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	want := map[string]string{"RateLimit-Limit": "5", "RateLimit-Remaining": "0", "RateLimit-Reset": "60", echo.HeaderRetryAfter: "60"}
+	for key, value := range want {
+		if got := rec.Header().Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestSignUp_Rejected(t *testing.T) {
+	ts := newTestServer(t)
+	// 学外のメールアドレスは登録できない
+	rec := ts.do(t, http.MethodPost, "/signup", "", `{"email":"taro@example.com","password":"secret","user_name":"taro"}`)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("POST /signup = %d, want %d (%s)", rec.Code, http.StatusInternalServerError, rec.Body)
+	}
+}
+
+// 同じメールアドレスでのサインアップはIPアドレスの制限とは別に回数を制限する
+func TestSignUp_PerAccountLimit(t *testing.T) {
+	ts := newTestServer(t)
+	signUp := func(email string) int {
+		return ts.do(t, http.MethodPost, "/signup", "", fmt.Sprintf(`{"email":%q,"password":"secret","user_name":"taro"}`, email)).Code
+	}
+	for i := 0; i < 5; i++ {
+		if code := signUp("taro@example.com"); code == http.StatusTooManyRequests {
+			t.Fatalf("%d回目の POST /signup = %d", i+1, code)
+		}
+	}
+	if code := signUp("TARO@example.com"); code != http.StatusTooManyRequests {
+		t.Errorf("上限を超えた POST /signup = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := signUp("jiro@example.com"); code == http.StatusTooManyRequests {
+		t.Errorf("別のメールアドレスの POST /signup = %d", code)
+	}
+	// ログインの回数とは別に数える
+	if rec := ts.do(t, http.MethodPost, "/login", "", `{"email":"taro@example.com","password":"password"}`); rec.Code == http.StatusTooManyRequests {
+		t.Errorf("POST /login = %d", rec.Code)
+	}
+}
+
+// X-Forwarded-Forは信頼するプロキシからのものだけ使う（httptestの接続元は192.0.2.1）
+func TestSignUp_PerIPLimit(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies string
+		wantLimited    bool
+	}{
+		{"プロキシの指定なし（X-Forwarded-Forを偽装しても同じIPとして数える）", "", true},
+		{"接続元が信頼するプロキシ", "192.0.2.0/24", false},
+		{"接続元が信頼しないプロキシ", "198.51.100.0/24", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Secret: "test-secret-0123456789abcdef0123456789", FEURL: "http://localhost:3000"}
+			if tt.trustedProxies != "" {
+				_, ipNet, err := net.ParseCIDR(tt.trustedProxies)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cfg.TrustedProxies = []*net.IPNet{ipNet}
+			}
+			ts := newTestServerWithConfig(t, cfg)
+			limited := false
+			for i := 0; i < 11; i++ {
+				body := fmt.Sprintf(`{"email":"user%d@example.com","password":"secret","user_name":"user%d"}`, i, i)
+				rec := ts.do(t, http.MethodPost, "/signup", "", body, "X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i), echo.HeaderXRealIP, fmt.Sprintf("203.0.113.%d", i))
+				limited = rec.Code == http.StatusTooManyRequests
+			}
+			if limited != tt.wantLimited {
+				t.Errorf("11回目の POST /signup が429 = %v, want %v", limited, tt.wantLimited)
+			}
+		})
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.createUser(t, "taro", false)
+	admin := ts.createUser(t, "admin", true)
+	userToken := ts.login(t, user)
+	adminToken := ts.login(t, admin)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		wantCode int
+	}{
+		{"ヘルスチェックはトークン不要", http.MethodGet, "/healthz", "", http.StatusOK},
+		{"トークンなし", http.MethodGet, "/quests", "", http.StatusUnauthorized},
+		{"不正なトークン", http.MethodGet, "/quests", "invalid", http.StatusUnauthorized},
+		{"ログイン済み", http.MethodGet, "/quests", userToken, http.StatusOK},
+		{"管理者用のエンドポイントに一般ユーザー", http.MethodPost, "/admin/categories", userToken, http.StatusForbidden},
+		{"管理者用のエンドポイントに管理者（カテゴリ名が未入力）", http.MethodPost, "/admin/categories", adminToken, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(t, tt.method, tt.path, tt.token, `{}`)
+			if rec.Code != tt.wantCode {
+				t.Errorf("%s %s = %d, want %d (%s)", tt.method, tt.path, rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+
+	// 利用停止にするとそれまでのトークンは使えない
+	if err := ts.ur.SetSuspended(context.Background(), user.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if rec := ts.do(t, http.MethodGet, "/quests", userToken, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("利用停止後の GET /quests = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestQuestEndpoints(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.createUser(t, "owner", false)
+	member := ts.createUser(t, "member", false)
+	ownerToken := ts.login(t, owner)
+	memberToken := ts.login(t, member)
+
+	rec := ts.do(t, http.MethodPost, "/quests", ownerToken, `{"title":"勉強会","max_participants":1,"tags":[]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /quests = %d (%s)", rec.Code, rec.Body)
+	}
+	quests := []model.QuestResponse{}
+	rec = ts.do(t, http.MethodGet, "/quests", memberToken, "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &quests); err != nil || len(quests) != 1 {
+		t.Fatalf("GET /quests = %d %s", rec.Code, rec.Body)
+	}
+	questId := quests[0].ID
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		wantCode int
+	}{
+		{"タイトルが未入力", http.MethodPost, "/quests", ownerToken, `{"title":""}`, http.StatusInternalServerError},
+		{"参加", http.MethodPost, fmt.Sprintf("/quests/join/%d", questId), memberToken, "", http.StatusNoContent},
+		{"定員に達している", http.MethodPost, fmt.Sprintf("/quests/join/%d", questId), ownerToken, "", http.StatusInternalServerError},
+		{"参加者一覧", http.MethodGet, fmt.Sprintf("/quests/participants/%d", questId), ownerToken, "", http.StatusOK},
+		{"募集主以外は編集用に取得できない", http.MethodGet, fmt.Sprintf("/quests/%d", questId), memberToken, "", http.StatusInternalServerError},
+		{"募集主以外は削除できない", http.MethodDelete, fmt.Sprintf("/quests/%d", questId), memberToken, "", http.StatusInternalServerError},
+		{"参加の取り消し", http.MethodDelete, fmt.Sprintf("/quests/cancel/%d", questId), memberToken, "", http.StatusNoContent},
+		{"募集主は削除できる", http.MethodDelete, fmt.Sprintf("/quests/%d", questId), ownerToken, "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if rec.Code != tt.wantCode {
+				t.Errorf("%s %s = %d, want %d (%s)", tt.method, tt.path, rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetAllQuests_NotModified(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.createUser(t, "owner", false)
+	member := ts.createUser(t, "member", false)
+	ownerToken := ts.login(t, owner)
+	memberToken := ts.login(t, member)
+	for i := 0; i < 2; i++ {
+		if rec := ts.do(t, http.MethodPost, "/quests", ownerToken, `{"title":"勉強会"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("POST /quests = %d (%s)", rec.Code, rec.Body)
+		}
+	}
+	quests := []model.QuestResponse{}
+	if rec := ts.do(t, http.MethodGet, "/quests", ownerToken, ""); json.Unmarshal(rec.Body.Bytes(), &quests) != nil || len(quests) != 2 {
+		t.Fatalf("GET /quests = %d %s", rec.Code, rec.Body)
+	}
+	questId := quests[0].ID
+	if rec := ts.do(t, http.MethodPost, fmt.Sprintf("/quests/join/%d", questId), memberToken, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("POST /quests/join = %d (%s)", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name   string
+		change func() int // 一覧に影響する変更（ステータスコードを返す）
+	}{
+		{"変更なし", nil},
+		{"参加者がユーザー名を変更", func() int {
+			return ts.do(t, http.MethodPut, "/users/userName", memberToken, `{"user_name":"member2"}`).Code
+		}},
+		{"クエストを削除", func() int {
+			return ts.do(t, http.MethodDelete, fmt.Sprintf("/quests/%d", questId), ownerToken, "").Code
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(t, http.MethodGet, "/quests", ownerToken, "")
+			etag := rec.Header().Get("ETag")
+			if rec.Code != http.StatusOK || etag == "" {
+				t.Fatalf("GET /quests = %d, ETag = %q", rec.Code, etag)
+			}
+			// 削除では更新日時が進まないので、一覧はLast-Modifiedを返さない
+			if lm := rec.Header().Get(echo.HeaderLastModified); lm != "" {
+				t.Errorf("Last-Modified = %q, want empty", lm)
+			}
+			wantCode := http.StatusNotModified
+			if tt.change != nil {
+				if code := tt.change(); code >= 300 {
+					t.Fatalf("変更のリクエスト = %d", code)
+				}
+				wantCode = http.StatusOK
+			}
+			if rec := ts.do(t, http.MethodGet, "/quests", ownerToken, "", "If-None-Match", etag); rec.Code != wantCode {
+				t.Errorf("If-None-Match付きの GET /quests = %d, want %d", rec.Code, wantCode)
+			}
+			since := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+			if rec := ts.do(t, http.MethodGet, "/quests", ownerToken, "", echo.HeaderIfModifiedSince, since); rec.Code != http.StatusOK {
+				t.Errorf("If-Modified-Since付きの GET /quests = %d, want %d", rec.Code, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestGetQuestById_NotModified(t *testing.T) {
+	ts := newTestServer(t)
+	owner := ts.createUser(t, "owner", false)
+	other := ts.createUser(t, "other", false)
+	ownerToken := ts.login(t, owner)
+	otherToken := ts.login(t, other)
+	if rec := ts.do(t, http.MethodPost, "/quests", ownerToken, `{"title":"勉強会"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("POST /quests = %d (%s)", rec.Code, rec.Body)
+	}
+	quests := []model.QuestResponse{}
+	rec := ts.do(t, http.MethodGet, "/quests", ownerToken, "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &quests); err != nil || len(quests) != 1 {
+		t.Fatalf("GET /quests = %d %s", rec.Code, rec.Body)
+	}
+	path := fmt.Sprintf("/quests/%d", quests[0].ID)
+	rec = ts.do(t, http.MethodGet, path, ownerToken, "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET %s = %d, ETag = %q", path, rec.Code, etag)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		header   []string
+		wantCode int
+	}{
+		{"募集主でETagが一致", ownerToken, []string{"If-None-Match", etag}, http.StatusNotModified},
+		{"募集主以外は一致するETagでも304にしない", otherToken, []string{"If-None-Match", "*"}, http.StatusInternalServerError},
+		{"募集主以外はIf-Modified-Sinceでも304にしない", otherToken, []string{echo.HeaderIfModifiedSince, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(t, http.MethodGet, path, tt.token, "", tt.header...)
+			if rec.Code != tt.wantCode {
+				t.Errorf("GET %s = %d, want %d (%s)", path, rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusNotModified && rec.Header().Get("ETag") != "" {
+				t.Error("募集主以外にETagを返している")
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.createUser(t, "taro", false)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"現在のパスワードが違う", `{"current_password":"wrong-password","new_password":"new-password"}`, http.StatusInternalServerError},
+		{"新しいパスワードが短い", fmt.Sprintf(`{"current_password":%q,"new_password":"abc"}`, testPassword), http.StatusInternalServerError},
+		{"JSONが不正", `{"current_password":`, http.StatusBadRequest},
+		{"正しい入力", fmt.Sprintf(`{"current_password":%q,"new_password":"new-password"}`, testPassword), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := ts.login(t, user)
+			rec := ts.do(t, http.MethodPut, "/users/me/password", token, tt.body)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("PUT /users/me/password = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			// それまでのトークンは使えなくなり、返されたトークンでこのセッションを続けられる
+			res := map[string]string{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if rec := ts.do(t, http.MethodGet, "/users/userInfo", token, ""); rec.Code != http.StatusUnauthorized {
+				t.Errorf("変更前のトークンで GET /users/userInfo = %d, want %d", rec.Code, http.StatusUnauthorized)
+			}
+			if rec := ts.do(t, http.MethodGet, "/users/userInfo", res["token"], ""); rec.Code != http.StatusOK {
+				t.Errorf("新しいトークンで GET /users/userInfo = %d, want %d", rec.Code, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.createUser(t, "taro", false)
+	token := ts.login(t, user)
+	// 他のアカウントが使っている学内メールアドレス
+	other := ts.createUser(t, "jiro", false)
+	ctx := context.Background()
+	if err := ts.ur.SetPendingEmail(ctx, other.ID, "s0000002@st.pu-toyama.ac.jp", "hash", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.ur.ConfirmPendingEmail(ctx, other.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		online   bool // 学内メールアドレスの名前解決が必要
+		wantCode int
+	}{
+		{"現在のパスワードが違う", `{"current_password":"wrong-password","new_email":"s0000001@st.pu-toyama.ac.jp"}`, false, http.StatusInternalServerError},
+		{"学外のメールアドレス", fmt.Sprintf(`{"current_password":%q,"new_email":"taro@example.com"}`, testPassword), false, http.StatusInternalServerError},
+		{"JSONが不正", `{"new_email":`, false, http.StatusBadRequest},
+		{"他のアカウントが使っているアドレス", fmt.Sprintf(`{"current_password":%q,"new_email":"s0000002@st.pu-toyama.ac.jp"}`, testPassword), true, http.StatusConflict},
+		{"正しい入力", fmt.Sprintf(`{"current_password":%q,"new_email":"s0000001@st.pu-toyama.ac.jp"}`, testPassword), true, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.online {
+				if _, err := net.LookupIP("st.pu-toyama.ac.jp"); err != nil {
+					t.Skip("学内メールアドレスのドメインを名前解決できないためスキップ:", err)
+				}
+			}
+			rec := ts.do(t, http.MethodPut, "/users/me/email", token, tt.body)
+			if rec.Code != tt.wantCode {
+				t.Errorf("PUT /users/me/email = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
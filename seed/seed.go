@@ -0,0 +1,320 @@
+package main
+
+/*
+開発用のデータを投入する
+ go run ./seed                    決まったデータを投入（何度実行しても同じ結果になる）
+ go run ./seed -random -users 30  ランダムなデータを投入（-seed を指定すると再現できる）
+
+ ・repository / usecase を通して作成するので、APIと同じバリデーションがかかる
+ ・既にあるユーザー（メールアドレス）・カテゴリ（名前）・クエスト（作成者とタイトル）は作成しない
+ ・GO_ENV=dev 以外では -force を付けないと実行しない
+*/
+
+import (
+	"bulletin-board-rest-api/config"
+	"bulletin-board-rest-api/db"
+	"bulletin-board-rest-api/logger"
+	"bulletin-board-rest-api/mailer"
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"bulletin-board-rest-api/usecase"
+	"bulletin-board-rest-api/validator"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const seedPassword = "password123" // 全ての開発用ユーザーのパスワード
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+type seeder struct {
+	ur repository.IUserRepository
+	qr repository.IQuestRepository
+	uu usecase.IUserUsecase
+	qu usecase.IQuestUsecase
+	cu usecase.ICategoryUsecase
+}
+
+// 作成するユーザー・クエストの元データ
+type userSeed struct {
+	Email    string
+	UserName string
+	Faculty  string
+	Grade    uint
+}
+
+type questSeed struct {
+	Owner            int // usersの添字
+	Title            string
+	Description      string
+	Category         string // categorySeedsの名前
+	Tags             []string
+	MaxParticipants  uint
+	StartInDays      int // 今日からの日数（負なら過去）
+	Hours            int
+	RequiresApproval bool
+	Participants     []int // usersの添字
+}
+
+var categorySeeds = []model.Category{
+	{Name: "勉強会", Icon: "book", Color: "#3B82F6", Fields: []model.CategoryField{
+		{Key: "subject", Label: "科目", Type: model.FieldTypeText},
+	}},
+	{Name: "スポーツ", Icon: "ball", Color: "#10B981", Fields: []model.CategoryField{
+		{Key: "beginner_ok", Label: "初心者歓迎", Type: model.FieldTypeBoolean},
+	}},
+	{Name: "ゲーム", Icon: "gamepad", Color: "#8B5CF6"},
+	{Name: "ボランティア", Icon: "heart", Color: "#F59E0B"},
+	{Name: "ごはん", Icon: "utensils", Color: "#EF4444"},
+}
+
+var userSeeds = []userSeed{
+	{"s2311001@st.pu-toyama.ac.jp", "taro", "工学部 情報システム工学科", 3},
+	{"s2311002@st.pu-toyama.ac.jp", "hanako", "工学部 知能ロボット工学科", 3},
+	{"s2411003@st.pu-toyama.ac.jp", "kenta", "工学部 電気電子工学科", 2},
+	{"s2411004@st.pu-toyama.ac.jp", "misaki", "工学部 生物工学科", 2},
+	{"s2511005@st.pu-toyama.ac.jp", "yuto", "工学部 情報システム工学科", 1},
+	{"s2511006@st.pu-toyama.ac.jp", "sakura", "工学部 環境・社会基盤工学科", 1},
+	{"tanaka@puc.pu-toyama.ac.jp", "tanaka", "情報基盤センター", 0},
+}
+
+var questSeeds = []questSeed{
+	{0, "線形代数の試験対策", "過去問を解きながら質問し合いましょう", "勉強会", []string{"線形代数", "試験対策"}, 8, 3, 2, false, []int{1, 2, 4}},
+	{1, "フットサルしませんか", "体育館で2時間ほど。経験不問です", "スポーツ", []string{"フットサル"}, 10, 5, 2, false, []int{0, 2, 3, 5}},
+	{2, "スマブラ大会", "学生会館でトーナメントをします", "ゲーム", []string{"スマブラ", "大会"}, 16, 10, 4, true, []int{0, 4}},
+	{3, "河川敷のゴミ拾い", "軍手とゴミ袋はこちらで用意します", "ボランティア", []string{"地域貢献"}, 0, 14, 3, false, []int{1, 5, 6}},
+	{4, "富山ブラックを食べに", "駅前のお店に行きます", "ごはん", []string{"ラーメン"}, 4, 1, 2, false, []int{0, 1, 2}},
+	{0, "Go言語もくもく会", "各自の作業を進めつつ雑談します", "勉強会", []string{"go", "プログラミング"}, 0, -7, 3, false, []int{2, 4, 6}},
+	{5, "バドミントン", "ラケットの貸し出しあります", "スポーツ", []string{"バドミントン"}, 6, -2, 2, false, []int{3}},
+	{6, "競プロ入門講座", "AtCoderの始め方を紹介します", "勉強会", []string{"競技プログラミング", "プログラミング"}, 20, 21, 2, true, []int{4, 5}},
+}
+
+func main() {
+	random := flag.Bool("random", false, "generate randomized data instead of the fixed fixtures")
+	randSeed := flag.Int64("seed", 0, "random seed for -random (0 = current time)")
+	users := flag.Int("users", 20, "number of users for -random")
+	quests := flag.Int("quests", 40, "number of quests for -random")
+	force := flag.Bool("force", false, "allow running outside GO_ENV=dev")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		exit(err)
+	}
+	if cfg.Env != "dev" && !*force {
+		exit(errors.New("refusing to seed outside GO_ENV=dev (use -force to override)"))
+	}
+	slog.SetDefault(logger.New(cfg.LogLevel))
+	dbConn := db.NewDB(cfg.DB)
+	defer db.CloseDB(dbConn)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM) // Ctrl+Cで実行中のクエリも止める
+	defer stop()
+	s := newSeeder(dbConn, cfg)
+	us, qs := userSeeds, questSeeds
+	if *random {
+		if *randSeed == 0 {
+			*randSeed = time.Now().UnixNano()
+		}
+		fmt.Println("random seed:", *randSeed) // 同じデータを作り直す時に使う
+		us, qs = generate(rand.New(rand.NewSource(*randSeed)), *users, *quests)
+	}
+	if err := s.run(ctx, us, qs); err != nil {
+		db.CloseDB(dbConn)
+		exit(err)
+	}
+	fmt.Printf("seeded %d users and %d quests (password: %s)\n", len(us), len(qs), seedPassword)
+}
+
+func newSeeder(dbConn *gorm.DB, cfg *config.Config) *seeder {
+	ur := repository.NewUserRepository(dbConn)
+	qr := repository.NewQuestRepository(dbConn)
+	nr := repository.NewNotificationRepository(dbConn)
+	tr := repository.NewTagRepository(dbConn)
+	cr := repository.NewCategoryRepository(dbConn)
+	tm := repository.NewTransactionManager(dbConn)
+	return &seeder{
+		ur: ur,
+		qr: qr,
+		uu: usecase.NewUserUsecase(ur, qr, nr, validator.NewUserValidator(), mailer.NewMailer(config.SMTPConfig{}), cfg, tm),
+		qu: usecase.NewQuestUsecase(qr, ur, nr, tr, cr, validator.NewQuestValidator(), tm),
+		cu: usecase.NewCategoryUsecase(cr, validator.NewCategoryValidator()),
+	}
+}
+
+func (s *seeder) run(ctx context.Context, users []userSeed, quests []questSeed) error {
+	categoryIds, err := s.seedCategories(ctx)
+	if err != nil {
+		return err
+	}
+	userIds := []uint{}
+	for _, u := range users {
+		id, err := s.seedUser(ctx, u)
+		if err != nil {
+			return fmt.Errorf("user %s: %w", u.Email, err)
+		}
+		userIds = append(userIds, id)
+	}
+	now := time.Now().In(jst)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, jst)
+	for _, q := range quests {
+		if err := s.seedQuest(ctx, q, userIds, categoryIds, today); err != nil {
+			return fmt.Errorf("quest %s: %w", q.Title, err)
+		}
+	}
+	return nil
+}
+
+// カテゴリ名からIDを引けるようにして返す
+func (s *seeder) seedCategories(ctx context.Context) (map[string]uint, error) {
+	ids := map[string]uint{}
+	existing, err := s.cu.GetAllCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range existing {
+		ids[c.Name] = c.ID
+	}
+	for _, c := range categorySeeds {
+		if _, ok := ids[c.Name]; ok {
+			continue
+		}
+		res, err := s.cu.CreateCategory(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("category %s: %w", c.Name, err)
+		}
+		ids[c.Name] = res.ID
+	}
+	return ids, nil
+}
+
+func (s *seeder) seedUser(ctx context.Context, u userSeed) (uint, error) {
+	user := model.User{}
+	err := s.ur.GetUserByEmail(ctx, &user, u.Email)
+	if err == nil {
+		return user.ID, nil // 作成済み
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	res, err := s.uu.SignUp(ctx, model.User{Email: u.Email, Password: seedPassword, UserName: u.UserName})
+	if err != nil {
+		return 0, err
+	}
+	if err := s.uu.UpdateProfile(ctx, res.ID, model.UpdateProfileRequest{
+		DisplayName: u.UserName,
+		Faculty:     u.Faculty,
+		Grade:       u.Grade,
+	}); err != nil {
+		return 0, err
+	}
+	return res.ID, nil
+}
+
+func (s *seeder) seedQuest(ctx context.Context, q questSeed, userIds []uint, categoryIds map[string]uint, today time.Time) error {
+	ownerId := userIds[q.Owner]
+	questId, err := s.findQuest(ctx, ownerId, q.Title)
+	if err != nil {
+		return err
+	}
+	if questId == 0 {
+		start := today.AddDate(0, 0, q.StartInDays).Add(18 * time.Hour) // 18時開始
+		categoryId := categoryIds[q.Category]
+		quest := model.Quest{
+			Title:            q.Title,
+			Description:      q.Description,
+			Category:         q.Category,
+			CategoryId:       &categoryId,
+			MaxParticipants:  q.MaxParticipants,
+			Deadline:         start.Add(-24 * time.Hour),
+			StartTime:        start,
+			EndTime:          start.Add(time.Duration(q.Hours) * time.Hour),
+			RequiresApproval: q.RequiresApproval,
+			Visibility:       model.VisibilityPublic,
+			UserId:           ownerId,
+			TagNames:         q.Tags,
+		}
+		if err := s.qu.CreateQuest(ctx, quest); err != nil {
+			return err
+		}
+		if questId, err = s.findQuest(ctx, ownerId, q.Title); err != nil {
+			return err
+		}
+	}
+	// 参加は既に参加していれば何もしないので、毎回実行してよい
+	for _, p := range q.Participants {
+		if p == q.Owner {
+			continue
+		}
+		err := s.qu.JoinQuest(ctx, userIds[p], questId, "")
+		if err != nil && !errors.Is(err, repository.ErrQuestFull) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 作成者とタイトルが一致するクエストのIDを返す（なければ0）
+func (s *seeder) findQuest(ctx context.Context, ownerId uint, title string) (uint, error) {
+	quests := []model.QuestSummary{}
+	if err := s.qr.GetUserQuestsFromDB(ctx, &quests, ownerId); err != nil {
+		return 0, err
+	}
+	for _, q := range quests {
+		if q.Title == title {
+			return q.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// ランダムなユーザーとクエストを作成する（同じrならいつも同じ結果になる）
+func generate(r *rand.Rand, userCount int, questCount int) ([]userSeed, []questSeed) {
+	faculties := []string{"情報システム工学科", "知能ロボット工学科", "電気電子工学科", "機械システム工学科", "生物工学科", "医薬品工学科"}
+	users := []userSeed{}
+	for i := 0; i < userCount; i++ {
+		year := 21 + r.Intn(5)
+		studentId := fmt.Sprintf("s%02d%05d", year, r.Intn(100000)) // 学籍番号をユーザー名にも使う
+		users = append(users, userSeed{
+			Email:    studentId + "@st.pu-toyama.ac.jp",
+			UserName: studentId,
+			Faculty:  "工学部 " + faculties[r.Intn(len(faculties))],
+			Grade:    uint(26 - year),
+		})
+	}
+	quests := []questSeed{}
+	for i := 0; i < questCount && len(users) > 0; i++ {
+		base := questSeeds[r.Intn(len(questSeeds))]
+		q := questSeed{
+			Owner:            r.Intn(len(users)),
+			Title:            fmt.Sprintf("%s #%d", string([]rune(base.Title)[:min(len([]rune(base.Title)), 14)]), i+1),
+			Description:      base.Description,
+			Category:         base.Category,
+			Tags:             base.Tags,
+			MaxParticipants:  uint(r.Intn(4) * 5), // 0（無制限）・5・10・15
+			StartInDays:      r.Intn(60) - 20,     // 20日前〜40日後
+			Hours:            1 + r.Intn(4),
+			RequiresApproval: r.Intn(4) == 0,
+		}
+		for j := r.Intn(8); j > 0; j-- {
+			q.Participants = append(q.Participants, r.Intn(len(users)))
+		}
+		quests = append(quests, q)
+	}
+	return users, quests
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "seed:", err)
+	os.Exit(1)
+}
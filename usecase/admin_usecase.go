@@ -0,0 +1,170 @@
+package usecase
+
+/* 管理者用のCLIから実行する運用作業のビジネスロジック */
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"bulletin-board-rest-api/validator"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type IAdminUsecase interface {
+	CreateAdmin(ctx context.Context, user model.User) (model.UserResponse, error)          // サインアップと同じ検証をして管理者を作成する
+	PromoteAdmin(ctx context.Context, identifier string) (model.UserResponse, error)       // identifierはメールアドレスかユーザー名
+	ResetPassword(ctx context.Context, identifier string, password string) (string, error) // passwordが空なら生成する。新しいパスワードを返す
+	SetSuspended(ctx context.Context, identifier string, suspended bool) (model.UserResponse, error)
+	TransferQuests(ctx context.Context, from string, to string, questId uint) (int64, error) // questIdが0なら全てのクエスト
+	Purge(ctx context.Context, notificationAge time.Duration) (model.PurgeResult, error)     // notificationAgeより古い既読のお知らせも削除する
+	GetStats(ctx context.Context) (model.AdminStats, error)
+}
+
+type adminUsecase struct {
+	ur repository.IUserRepository
+	qr repository.IQuestRepository
+	ar repository.IAdminRepository
+	uu IUserUsecase
+	uv validator.IUserValidator
+	tm repository.ITransactionManager
+}
+
+func NewAdminUsecase(ur repository.IUserRepository, qr repository.IQuestRepository, ar repository.IAdminRepository, uu IUserUsecase, uv validator.IUserValidator, tm repository.ITransactionManager) IAdminUsecase {
+	return &adminUsecase{ur, qr, ar, uu, uv, tm}
+}
+
+func (au *adminUsecase) CreateAdmin(ctx context.Context, user model.User) (model.UserResponse, error) {
+	// 管理者にできなかった場合に一般ユーザーとして残らないようにする
+	var res model.UserResponse
+	err := au.tm.Do(ctx, func(ctx context.Context) error {
+		var err error
+		if res, err = au.uu.SignUp(ctx, user); err != nil {
+			return err
+		}
+		return au.ur.SetAdmin(ctx, res.ID, true)
+	})
+	if err != nil {
+		return model.UserResponse{}, err
+	}
+	return res, nil
+}
+
+func (au *adminUsecase) PromoteAdmin(ctx context.Context, identifier string) (model.UserResponse, error) {
+	user, err := au.findUser(ctx, identifier)
+	if err != nil {
+		return model.UserResponse{}, err
+	}
+	if err := au.ur.SetAdmin(ctx, user.ID, true); err != nil {
+		return model.UserResponse{}, err
+	}
+	return toUserResponse(user), nil
+}
+
+func (au *adminUsecase) ResetPassword(ctx context.Context, identifier string, password string) (string, error) {
+	user, err := au.findUser(ctx, identifier)
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		password = base64.RawURLEncoding.EncodeToString(b) // 16文字
+	}
+	if err := au.uv.ValidatePassword(password); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		return "", err
+	}
+	// パスワードの更新と同時に、発行済みのJWTも無効になる
+	if err := au.ur.UpdatePassword(ctx, user.ID, string(hash)); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+func (au *adminUsecase) SetSuspended(ctx context.Context, identifier string, suspended bool) (model.UserResponse, error) {
+	user, err := au.findUser(ctx, identifier)
+	if err != nil {
+		return model.UserResponse{}, err
+	}
+	if suspended && user.IsAdmin {
+		return model.UserResponse{}, fmt.Errorf("cannot suspend an admin user")
+	}
+	if err := au.ur.SetSuspended(ctx, user.ID, suspended); err != nil {
+		return model.UserResponse{}, err
+	}
+	return toUserResponse(user), nil
+}
+
+func (au *adminUsecase) TransferQuests(ctx context.Context, from string, to string, questId uint) (int64, error) {
+	var transferred int64
+	err := au.tm.Do(ctx, func(ctx context.Context) error {
+		fromUser, err := au.findUser(ctx, from)
+		if err != nil {
+			return err
+		}
+		toUser, err := au.findUser(ctx, to)
+		if err != nil {
+			return err
+		}
+		if fromUser.ID == toUser.ID {
+			return fmt.Errorf("source and destination users are the same")
+		}
+		if toUser.Suspended {
+			return fmt.Errorf("cannot transfer quests to a suspended user")
+		}
+		transferred, err = au.qr.TransferQuests(ctx, fromUser.ID, toUser.ID, questId)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return transferred, nil
+}
+
+func (au *adminUsecase) Purge(ctx context.Context, notificationAge time.Duration) (model.PurgeResult, error) {
+	result := model.PurgeResult{}
+	now := time.Now()
+	if err := au.ar.PurgeExpired(ctx, &result, now, now.Add(-notificationAge)); err != nil {
+		return model.PurgeResult{}, err
+	}
+	return result, nil
+}
+
+func (au *adminUsecase) GetStats(ctx context.Context) (model.AdminStats, error) {
+	stats := model.AdminStats{}
+	if err := au.ar.GetStats(ctx, &stats, time.Now()); err != nil {
+		return model.AdminStats{}, err
+	}
+	return stats, nil
+}
+
+// メールアドレス（@を含む場合）またはユーザー名でユーザーを探す
+func (au *adminUsecase) findUser(ctx context.Context, identifier string) (model.User, error) {
+	user := model.User{}
+	identifier = strings.TrimSpace(identifier)
+	var err error
+	if strings.Contains(identifier, "@") {
+		err = au.ur.GetUserByEmail(ctx, &user, identifier)
+	} else {
+		err = au.ur.GetUserByUserName(ctx, &user, identifier)
+	}
+	if err != nil {
+		return model.User{}, fmt.Errorf("user %q: %w", identifier, err)
+	}
+	return user, nil
+}
+
+func toUserResponse(user model.User) model.UserResponse {
+	return model.UserResponse{ID: user.ID, Email: user.Email, UserName: user.UserName}
+}
@@ -0,0 +1,89 @@
+package usecase
+
+/* カテゴリに関連するビジネスロジックを実装する部分 */
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"bulletin-board-rest-api/validator"
+	"context"
+)
+
+type ICategoryUsecase interface {
+	GetAllCategories(ctx context.Context) ([]model.CategoryResponse, error)
+	CreateCategory(ctx context.Context, category model.Category) (model.CategoryResponse, error)
+	UpdateCategory(ctx context.Context, category model.Category, categoryId uint) error
+	DeleteCategory(ctx context.Context, categoryId uint) error
+}
+
+type categoryUsecase struct {
+	cr repository.ICategoryRepository
+	cv validator.ICategoryValidator
+}
+
+func NewCategoryUsecase(cr repository.ICategoryRepository, cv validator.ICategoryValidator) ICategoryUsecase {
+	return &categoryUsecase{cr, cv}
+}
+
+/* CategoryをCategoryResponseに変換するヘルパー関数toCategoryResponse */
+func toCategoryResponse(category model.Category) model.CategoryResponse {
+	res := model.CategoryResponse{
+		ID:     category.ID,
+		Name:   category.Name,
+		Icon:   category.Icon,
+		Color:  category.Color,
+		Fields: make([]model.CategoryFieldResponse, 0, len(category.Fields)),
+	}
+	for _, f := range category.Fields {
+		res.Fields = append(res.Fields, model.CategoryFieldResponse{
+			Key:      f.Key,
+			Label:    f.Label,
+			Type:     f.Type,
+			Required: f.Required,
+		})
+	}
+	return res
+}
+
+func (cu *categoryUsecase) GetAllCategories(ctx context.Context) ([]model.CategoryResponse, error) {
+	categories := []model.Category{}
+	if err := cu.cr.GetAllCategories(ctx, &categories); err != nil {
+		return nil, err
+	}
+	resCategories := []model.CategoryResponse{}
+	for _, category := range categories {
+		resCategories = append(resCategories, toCategoryResponse(category))
+	}
+	return resCategories, nil
+}
+
+func (cu *categoryUsecase) CreateCategory(ctx context.Context, category model.Category) (model.CategoryResponse, error) {
+	if err := cu.cv.CategoryValidate(category); err != nil {
+		return model.CategoryResponse{}, err
+	}
+	category.ID = 0
+	for i := range category.Fields {
+		category.Fields[i].ID = 0
+	}
+	if err := cu.cr.CreateCategory(ctx, &category); err != nil {
+		return model.CategoryResponse{}, err
+	}
+	return toCategoryResponse(category), nil
+}
+
+func (cu *categoryUsecase) UpdateCategory(ctx context.Context, category model.Category, categoryId uint) error {
+	if err := cu.cv.CategoryValidate(category); err != nil {
+		return err
+	}
+	if err := cu.cr.UpdateCategory(ctx, &category, categoryId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cu *categoryUsecase) DeleteCategory(ctx context.Context, categoryId uint) error {
+	if err := cu.cr.DeleteCategory(ctx, categoryId); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+package usecase
+
+/* ユースケースが返すエラー（コントローラでステータスコードを分けるために使う） */
+
+import (
+	"errors"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password") // メールアドレスが登録されていないかパスワードが違う
+)
@@ -3,33 +3,60 @@ package usecase
 /* クエストに関連するビジネスロジックを実装する部分 */
 
 import (
+	"bulletin-board-rest-api/metrics"
 	"bulletin-board-rest-api/model"
 	"bulletin-board-rest-api/repository"
 	"bulletin-board-rest-api/validator"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type IQuestUsecase interface {
-	GetAllQuests() ([]model.QuestResponse, error)
-	GetUserQuests(userId uint) ([]model.QuestResponse, error)
-	GetJoinedQuests(userId uint) ([]model.QuestResponse, error)
-	GetQuestById(userId uint, questId uint) (model.EditQuestResponse, error)
-	CreateQuest(quest model.Quest) error
-	UpdateQuest(quest model.Quest, userId uint, questId uint) error
-	DeleteQuest(userId uint, questId uint) error
-	JoinQuest(userId uint, questId uint) error
-	CancelQuest(userId uint, questId uint) error
+	GetAllQuests(ctx context.Context, userId uint, filter model.QuestFilter) ([]model.QuestResponse, error) // userIdは参加状態を返すログインユーザー
+	GetUserQuests(ctx context.Context, userId uint) ([]model.QuestResponse, error)
+	GetJoinedQuests(ctx context.Context, userId uint) ([]model.QuestResponse, error)
+	GetQuestById(ctx context.Context, userId uint, questId uint) (model.EditQuestResponse, error)
+	CreateQuest(ctx context.Context, quest model.Quest) error
+	UpdateQuest(ctx context.Context, quest model.Quest, userId uint, questId uint) error
+	PatchQuest(ctx context.Context, patch []byte, userId uint, questId uint) error // JSON Merge Patch（RFC 7396）による部分更新
+	DeleteQuest(ctx context.Context, userId uint, questId uint) error
+	GetQuestForView(ctx context.Context, userId uint, questId uint, token string) (model.QuestResponse, error)         // 公開範囲に応じて誰でも見られる詳細（招待制はtokenがあれば参加前でも見られる）
+	GetParticipants(ctx context.Context, userId uint, questId uint, token string) ([]model.ParticipantResponse, error) // 参加確定者の全員（詳細と同じ公開範囲）
+	JoinQuest(ctx context.Context, userId uint, questId uint, token string) error
+	CancelQuest(ctx context.Context, userId uint, questId uint) error
+	GetJoinRequests(ctx context.Context, userId uint, questId uint) ([]model.JoinRequestResponse, error)
+	ApproveJoinRequest(ctx context.Context, userId uint, questId uint, participantId uint, message string) error
+	RejectJoinRequest(ctx context.Context, userId uint, questId uint, participantId uint, message string) error
+	RemoveParticipant(ctx context.Context, userId uint, questId uint, participantId uint, reason string) error
+	BanParticipant(ctx context.Context, userId uint, questId uint, participantId uint, reason string) error
+	CreateInvite(ctx context.Context, userId uint, questId uint, req model.CreateInviteRequest) (model.QuestInviteResponse, error)
+	GetInvites(ctx context.Context, userId uint, questId uint) ([]model.QuestInviteResponse, error)
+	DeleteInvite(ctx context.Context, userId uint, questId uint, token string) error
+	GetQuestsStamp(ctx context.Context) (model.QuestStamp, error)
+	GetQuestStamp(ctx context.Context, questId uint) (model.QuestStamp, error)
 }
 
 type questUsecase struct {
 	// repositoryのinterfaceに依存
 	qr repository.IQuestRepository //IQuestRepositoryを実装した構造体
 	ur repository.IUserRepository
+	nr repository.INotificationRepository
+	tr repository.ITagRepository
+	cr repository.ICategoryRepository
 	qv validator.IQuestValidator
+	tm repository.ITransactionManager // 複数のリポジトリの操作をまとめる
 }
 
-func NewQuestUsecase(qr repository.IQuestRepository, ur repository.IUserRepository, qv validator.IQuestValidator) IQuestUsecase {
-	return &questUsecase{qr, ur, qv}
+func NewQuestUsecase(qr repository.IQuestRepository, ur repository.IUserRepository, nr repository.INotificationRepository, tr repository.ITagRepository, cr repository.ICategoryRepository, qv validator.IQuestValidator, tm repository.ITransactionManager) IQuestUsecase {
+	return &questUsecase{qr, ur, nr, tr, cr, qv, tm}
 }
 
 /* ゼロ値をnilに変換するヘルパー関数nilIfZero */
@@ -40,110 +67,112 @@ func nilIfZero(t time.Time) *time.Time {
 	return &t
 }
 
-func (qu *questUsecase) GetAllQuests() ([]model.QuestResponse, error) {
-	quests := []model.Quest{}
-	if err := qu.qr.GetAllQuestsFromDB(&quests); err != nil {
-		return nil, err
+/* タグ名の配列を取り出すヘルパー関数tagNames */
+func tagNames(tags []model.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
 	}
+	return names
+}
 
-	resQuests := []model.QuestResponse{} // QuestResponseの空の配列（スライス）を作成
-	for _, quest := range quests {       // クエスト一覧の中身を1つずつ取り出す
-		res := model.QuestResponse{
-			ID:              quest.ID,
-			Title:           quest.Title,
-			Description:     quest.Description,
-			Category:        quest.Category,
-			MaxParticipants: quest.MaxParticipants,
-			Deadline:        nilIfZero(quest.Deadline),
-			StartTime:       nilIfZero(quest.StartTime),
-			EndTime:         nilIfZero(quest.EndTime),
-			// Image:           quest.Image,
-			URL:          quest.URL,
-			CreatedAt:    quest.CreatedAt,
-			UpdatedAt:    quest.UpdatedAt,
-			UserName:     quest.User.UserName,                        // User構造体のUserNameを取得
-			Participants: make([]string, 0, len(quest.Participants)), // 参加者の名前の空のリストを作成
-		}
+/* QuestSummaryをQuestResponseに変換するヘルパー関数toQuestResponse（参加者は参加確定者の一部のみ） */
+func toQuestResponse(quest model.QuestSummary) model.QuestResponse {
+	participants := []string(quest.ParticipantPreview)
+	if participants == nil {
+		participants = []string{}
+	}
+	tags := []string(quest.TagNames)
+	if tags == nil {
+		tags = []string{}
+	}
+	return model.QuestResponse{
+		ID:               quest.ID,
+		Title:            quest.Title,
+		Description:      quest.Description,
+		Category:         quest.Category,
+		CategoryId:       quest.CategoryId,
+		CustomFields:     quest.CustomFields,
+		MaxParticipants:  quest.MaxParticipants,
+		Deadline:         nilIfZero(quest.Deadline),
+		StartTime:        nilIfZero(quest.StartTime),
+		EndTime:          nilIfZero(quest.EndTime),
+		URL:              quest.URL,
+		RequiresApproval: quest.RequiresApproval,
+		Visibility:       quest.Visibility,
+		CreatedAt:        quest.CreatedAt,
+		UpdatedAt:        quest.UpdatedAt,
+		UserName:         quest.UserName,
+		Participants:     participants,
+		ParticipantCount: quest.ParticipantCount,
+		Joined:           quest.JoinStatus == model.ParticipantApproved || quest.JoinStatus == model.ParticipantPending,
+		Tags:             tags,
+		JoinStatus:       quest.JoinStatus,
+	}
+}
+
+/* QuestSummaryの配列をQuestResponseの配列に変換する */
+func toQuestResponses(quests []model.QuestSummary) []model.QuestResponse {
+	resQuests := make([]model.QuestResponse, 0, len(quests))
+	for _, quest := range quests {
+		resQuests = append(resQuests, toQuestResponse(quest))
+	}
+	return resQuests
+}
+
+/* nilをゼロ値に変換するヘルパー関数zeroIfNil（nilIfZeroの逆） */
+func zeroIfNil(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
 
-		//* クエスト参加者情報から名前だけ取り出して配列に格納
-		for _, p := range quest.Participants {
-			res.Participants = append(res.Participants, p.User.UserName)
+func (qu *questUsecase) GetAllQuests(ctx context.Context, userId uint, filter model.QuestFilter) ([]model.QuestResponse, error) {
+	// 絞り込みのタグを正規化し、別名は統合先のタグに置き換える
+	// 同じタグになる指定は1つにまとめる（ANDの件数の比較が合わなくなるため）
+	slugs := []string{}
+	seen := map[string]bool{}
+	for _, name := range filter.Tags {
+		if slug := normalizeTagSlug(name); slug != "" {
+			resolved, err := qu.tr.ResolveSlug(ctx, slug)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[resolved] {
+				seen[resolved] = true
+				slugs = append(slugs, resolved)
+			}
 		}
-		resQuests = append(resQuests, res)
 	}
-	return resQuests, nil
+	filter.Tags = slugs
+
+	quests := []model.QuestSummary{}
+	if err := qu.qr.GetAllQuestsFromDB(ctx, &quests, userId, filter); err != nil {
+		return nil, err
+	}
+	return toQuestResponses(quests), nil
 }
 
-func (qu *questUsecase) GetUserQuests(userId uint) ([]model.QuestResponse, error) {
-	quests := []model.Quest{}                                          //Questの配列（スライス）を作成
-	if err := qu.qr.GetUserQuestsFromDB(&quests, userId); err != nil { //questRepositoryのGetAllQuestsFromDBを呼び出す -> questsに格納
+func (qu *questUsecase) GetUserQuests(ctx context.Context, userId uint) ([]model.QuestResponse, error) {
+	quests := []model.QuestSummary{}                                        //QuestSummaryの配列（スライス）を作成
+	if err := qu.qr.GetUserQuestsFromDB(ctx, &quests, userId); err != nil { //questRepositoryのGetUserQuestsFromDBを呼び出す -> questsに格納
 		return nil, err
 	}
-	// 成功したときの処理
-	resQuests := []model.QuestResponse{} //QuestResponseの配列を作成
-	for _, quest := range quests {       //questsの中身を1つずつ取り出す
-		res := model.QuestResponse{
-			ID:              quest.ID,
-			Title:           quest.Title,
-			Description:     quest.Description,
-			Category:        quest.Category,
-			MaxParticipants: quest.MaxParticipants,
-			Deadline:        nilIfZero(quest.Deadline),
-			StartTime:       nilIfZero(quest.StartTime),
-			EndTime:         nilIfZero(quest.EndTime),
-			// Image:           quest.Image,
-			URL:          quest.URL,
-			CreatedAt:    quest.CreatedAt,
-			UpdatedAt:    quest.UpdatedAt,
-			UserName:     quest.User.UserName,
-			Participants: make([]string, 0, len(quest.Participants)),
-		}
-
-		//* クエスト参加者情報から名前だけ取り出して配列に格納
-		for _, p := range quest.Participants {
-			res.Participants = append(res.Participants, p.User.UserName)
-		}
-		resQuests = append(resQuests, res) //resQuestsにmodel.QuestResponseを追加
-	}
-	return resQuests, nil
-}
-
-func (qu *questUsecase) GetJoinedQuests(userId uint) ([]model.QuestResponse, error) {
-	quests := []model.Quest{}
-	if err := qu.qr.GetJoinedQuestsFromDB(&quests, userId); err != nil {
+	return toQuestResponses(quests), nil
+}
+
+func (qu *questUsecase) GetJoinedQuests(ctx context.Context, userId uint) ([]model.QuestResponse, error) {
+	quests := []model.QuestSummary{}
+	if err := qu.qr.GetJoinedQuestsFromDB(ctx, &quests, userId); err != nil {
 		return nil, err
 	}
-	resQuests := []model.QuestResponse{}
-	for _, quest := range quests {
-		res := model.QuestResponse{
-			ID:              quest.ID,
-			Title:           quest.Title,
-			Description:     quest.Description,
-			Category:        quest.Category,
-			MaxParticipants: quest.MaxParticipants,
-			Deadline:        nilIfZero(quest.Deadline),
-			StartTime:       nilIfZero(quest.StartTime),
-			EndTime:         nilIfZero(quest.EndTime),
-			// Image:           quest.Image,
-			URL:          quest.URL,
-			CreatedAt:    quest.CreatedAt,
-			UpdatedAt:    quest.UpdatedAt,
-			UserName:     quest.User.UserName,
-			Participants: make([]string, 0, len(quest.Participants)),
-		}
-
-		//* クエスト参加者情報から名前だけ取り出して配列に格納
-		for _, p := range quest.Participants {
-			res.Participants = append(res.Participants, p.User.UserName)
-		}
-		resQuests = append(resQuests, res)
-	}
-	return resQuests, nil
-}
-
-func (qu *questUsecase) GetQuestById(userId uint, questId uint) (model.EditQuestResponse, error) {
-	quest := model.Quest{}                                              //Questの空の構造体を作成
-	if err := qu.qr.GetQuestById(&quest, userId, questId); err != nil { //空の構造体とuser・questのIDを渡す
+	return toQuestResponses(quests), nil // ログインユーザー自身の参加状態（承認待ちなど）も含まれる
+}
+
+func (qu *questUsecase) GetQuestById(ctx context.Context, userId uint, questId uint) (model.EditQuestResponse, error) {
+	quest := model.Quest{}                                                   //Questの空の構造体を作成
+	if err := qu.qr.GetQuestById(ctx, &quest, userId, questId); err != nil { //空の構造体とuser・questのIDを渡す
 		return model.EditQuestResponse{}, err
 	}
 	resQuest := model.EditQuestResponse{ // QuestResponseのインスタンスを作成
@@ -151,53 +180,476 @@ func (qu *questUsecase) GetQuestById(userId uint, questId uint) (model.EditQuest
 		Title:           quest.Title,
 		Description:     quest.Description,
 		Category:        quest.Category,
+		CategoryId:      quest.CategoryId,
+		CustomFields:    quest.CustomFields,
 		MaxParticipants: quest.MaxParticipants,
 		Deadline:        nilIfZero(quest.Deadline),
 		StartTime:       nilIfZero(quest.StartTime),
 		EndTime:         nilIfZero(quest.EndTime),
 		// Image:           quest.Image,
-		URL: quest.URL,
+		URL:              quest.URL,
+		RequiresApproval: quest.RequiresApproval,
+		Visibility:       quest.Visibility,
+		Tags:             tagNames(quest.Tags),
 	}
 	return resQuest, nil
 }
 
-func (qu *questUsecase) CreateQuest(quest model.Quest) error {
-	if err := qu.qv.QuestValidate(quest); err != nil {
+func (qu *questUsecase) GetQuestForView(ctx context.Context, userId uint, questId uint, token string) (model.QuestResponse, error) {
+	quest := model.QuestSummary{}
+	if err := qu.qr.GetQuestForView(ctx, &quest, userId, questId); err != nil {
+		return model.QuestResponse{}, err
+	}
+	if err := qu.checkView(ctx, quest, userId, token); err != nil {
+		return model.QuestResponse{}, err
+	}
+	return toQuestResponse(quest), nil
+}
+
+func (qu *questUsecase) GetParticipants(ctx context.Context, userId uint, questId uint, token string) ([]model.ParticipantResponse, error) {
+	quest := model.QuestSummary{}
+	if err := qu.qr.GetQuestForView(ctx, &quest, userId, questId); err != nil {
+		return nil, err
+	}
+	if err := qu.checkView(ctx, quest, userId, token); err != nil {
+		return nil, err
+	}
+	participants := []model.QuestParticipant{}
+	if err := qu.qr.GetParticipants(ctx, &participants, questId); err != nil {
+		return nil, err
+	}
+	resParticipants := []model.ParticipantResponse{}
+	for _, p := range participants {
+		resParticipants = append(resParticipants, model.ParticipantResponse{
+			UserId:   p.UserId,
+			UserName: p.User.UserName,
+			JoinedAt: p.JoinedAt,
+		})
+	}
+	return resParticipants, nil
+}
+
+/* 招待制のクエストは募集主と参加者（申請中を含む）以外には存在しないものとして扱う */
+func canView(quest model.QuestSummary, userId uint) bool {
+	if quest.Visibility != model.VisibilityInviteOnly || quest.UserId == userId {
+		return true
+	}
+	return quest.JoinStatus == model.ParticipantApproved || quest.JoinStatus == model.ParticipantPending
+}
+
+/* 参加前でも有効な招待トークンを持っていれば見られる（トークンの使用回数は増やさない） */
+func (qu *questUsecase) checkView(ctx context.Context, quest model.QuestSummary, userId uint, token string) error {
+	if canView(quest, userId) {
+		return nil
+	}
+	if token != "" {
+		err := qu.qr.CheckInvite(ctx, quest.ID, token)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	return fmt.Errorf("object does not exist")
+}
+
+func (qu *questUsecase) CreateQuest(ctx context.Context, quest model.Quest) error {
+	if quest.Visibility == "" {
+		quest.Visibility = model.VisibilityPublic // 指定がなければ公開
+	}
+	if quest.TagNames == nil && quest.Category != "" {
+		quest.TagNames = []string{quest.Category} // タグの指定がなければ従来のカテゴリをタグとして扱う
+	}
+	if err := qu.validateQuest(ctx, quest); err != nil {
 		return err
 	}
-	if err := qu.qr.CreateQuest(&quest); err != nil {
+	// タグの作成とクエストの作成を1つのトランザクションで行う（失敗した場合に使われないタグを残さない）
+	err := qu.tm.Do(ctx, func(ctx context.Context) error {
+		tags, err := qu.resolveTags(ctx, quest.TagNames)
+		if err != nil {
+			return err
+		}
+		quest.Tags = tags
+		quest.ID = 0 // やり直した場合に前回の採番が残らないようにする
+		return qu.qr.CreateQuest(ctx, &quest)
+	})
+	if err != nil {
 		return err
 	}
+	metrics.QuestsCreated.Inc()
 	return nil
 }
 
-func (qu *questUsecase) UpdateQuest(quest model.Quest, userId uint, questId uint) error {
+/* クエスト本体と、カテゴリごとの追加項目の両方を検証する */
+func (qu *questUsecase) validateQuest(ctx context.Context, quest model.Quest) error {
 	if err := qu.qv.QuestValidate(quest); err != nil {
 		return err
 	}
-	if err := qu.qr.UpdateQuest(&quest, userId, questId); err != nil {
+	defs := []model.CategoryField{}
+	if quest.CategoryId != nil {
+		category := model.Category{}
+		if err := qu.cr.GetCategoryById(ctx, &category, *quest.CategoryId); err != nil {
+			return err
+		}
+		defs = category.Fields
+	}
+	if err := qu.qv.QuestCustomFieldsValidate(quest.CustomFields, defs); err != nil {
+		return err
+	}
+	return nil
+}
+
+/* タグ名から（必要なら作成して）タグを取得する。同じタグになる名前は1つにまとめる */
+func (qu *questUsecase) resolveTags(ctx context.Context, names []string) ([]model.Tag, error) {
+	tags := []model.Tag{}
+	seen := map[uint]bool{}
+	for _, name := range names {
+		slug := normalizeTagSlug(name)
+		if slug == "" {
+			continue
+		}
+		tag := model.Tag{}
+		if err := qu.tr.FindOrCreateTag(ctx, &tag, slug, strings.TrimSpace(name)); err != nil {
+			return nil, err
+		}
+		if !seen[tag.ID] {
+			seen[tag.ID] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+/* タグが指定されていれば（nilでなければ）クエストのタグを置き換える */
+func (qu *questUsecase) replaceTags(ctx context.Context, questId uint, names []string) error {
+	if names == nil {
+		return nil
+	}
+	tags, err := qu.resolveTags(ctx, names)
+	if err != nil {
+		return err
+	}
+	if err := qu.qr.ReplaceQuestTags(ctx, &model.Quest{ID: questId}, tags); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (qu *questUsecase) UpdateQuest(ctx context.Context, quest model.Quest, userId uint, questId uint) error {
+	if quest.Visibility == "" {
+		quest.Visibility = model.VisibilityPublic
+	}
+	if err := qu.validateQuest(ctx, quest); err != nil {
+		return err
+	}
+	// 本体とタグの更新を1つのトランザクションで行う
+	return qu.tm.Do(ctx, func(ctx context.Context) error {
+		if err := qu.qr.UpdateQuest(ctx, &quest, userId, questId); err != nil {
+			return err
+		}
+		return qu.replaceTags(ctx, questId, quest.TagNames)
+	})
+}
+
+/* PATCHで変更できる項目（JSON Merge Patchの適用対象のドキュメント） */
+type questPatchDoc struct {
+	Title            string             `json:"title"`
+	Description      string             `json:"description"`
+	Category         string             `json:"category"`
+	CategoryId       *uint              `json:"category_id"`
+	CustomFields     model.CustomFields `json:"custom_fields"`
+	MaxParticipants  uint               `json:"max_participants"`
+	Deadline         *time.Time         `json:"deadline"`
+	StartTime        *time.Time         `json:"start_time"`
+	EndTime          *time.Time         `json:"end_time"`
+	URL              string             `json:"url"`
+	RequiresApproval bool               `json:"requires_approval"`
+	Visibility       string             `json:"visibility"`
+	Tags             []string           `json:"tags"`
+}
+
+/*
+* RFC 7396 のマージ処理
+* patchがオブジェクトでなければpatchで置き換え、nullのメンバーは削除（クリア）する
+ */
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+func (qu *questUsecase) PatchQuest(ctx context.Context, patch []byte, userId uint, questId uint) error {
+	var patchObj map[string]interface{}
+	if err := json.Unmarshal(patch, &patchObj); err != nil || patchObj == nil {
+		return fmt.Errorf("patch must be a JSON object")
+	}
+
+	// 変更できない項目が含まれていればエラー
+	allowed := map[string]bool{"title": true, "description": true, "category": true, "category_id": true, "custom_fields": true, "max_participants": true,
+		"deadline": true, "start_time": true, "end_time": true, "url": true, "requires_approval": true, "visibility": true, "tags": true}
+	for key := range patchObj {
+		if !allowed[key] {
+			return fmt.Errorf("field %q cannot be patched", key)
+		}
+	}
+	// 取得・マージ・更新を1つのトランザクションで行う
+	return qu.tm.Do(ctx, func(ctx context.Context) error {
+		return qu.applyPatch(ctx, patchObj, userId, questId)
+	})
+}
+
+/* 現在のクエストにパッチを適用して保存する */
+func (qu *questUsecase) applyPatch(ctx context.Context, patchObj map[string]interface{}, userId uint, questId uint) error {
+	// 現在のクエストを取得（作成者のクエストのみ）
+	quest := model.Quest{}
+	if err := qu.qr.GetQuestById(ctx, &quest, userId, questId); err != nil {
+		return err
+	}
+	current, err := json.Marshal(questPatchDoc{
+		Title:            quest.Title,
+		Description:      quest.Description,
+		Category:         quest.Category,
+		CategoryId:       quest.CategoryId,
+		CustomFields:     quest.CustomFields,
+		MaxParticipants:  quest.MaxParticipants,
+		Deadline:         nilIfZero(quest.Deadline),
+		StartTime:        nilIfZero(quest.StartTime),
+		EndTime:          nilIfZero(quest.EndTime),
+		URL:              quest.URL,
+		RequiresApproval: quest.RequiresApproval,
+		Visibility:       quest.Visibility,
+		Tags:             tagNames(quest.Tags),
+	})
+	if err != nil {
+		return err
+	}
+	var target interface{}
+	if err := json.Unmarshal(current, &target); err != nil {
+		return err
+	}
+
+	// マージした結果を構造体に戻す（削除された項目はゼロ値になる）
+	merged, err := json.Marshal(mergePatch(target, patchObj))
+	if err != nil {
+		return err
+	}
+	doc := questPatchDoc{}
+	if err := json.Unmarshal(merged, &doc); err != nil {
+		return err
+	}
+	quest.Title = doc.Title
+	quest.Description = doc.Description
+	quest.Category = doc.Category
+	quest.CategoryId = doc.CategoryId
+	quest.CustomFields = doc.CustomFields
+	quest.MaxParticipants = doc.MaxParticipants
+	quest.Deadline = zeroIfNil(doc.Deadline)
+	quest.StartTime = zeroIfNil(doc.StartTime)
+	quest.EndTime = zeroIfNil(doc.EndTime)
+	quest.URL = doc.URL
+	quest.RequiresApproval = doc.RequiresApproval
+	quest.Visibility = doc.Visibility
+	if quest.Visibility == "" {
+		quest.Visibility = model.VisibilityPublic // nullでクリアされたら公開に戻す
+	}
+	quest.TagNames = doc.Tags
+	if quest.TagNames == nil {
+		quest.TagNames = []string{} // nullでクリアされたらタグを全て外す
+	}
+
+	// バリデーションはマージ後の結果に対して行う
+	if err := qu.validateQuest(ctx, quest); err != nil {
+		return err
+	}
+	if err := qu.qr.UpdateQuest(ctx, &quest, userId, questId); err != nil {
+		return err
+	}
+	if err := qu.replaceTags(ctx, questId, quest.TagNames); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (qu *questUsecase) DeleteQuest(ctx context.Context, userId uint, questId uint) error {
+	if err := qu.qr.DeleteQuest(ctx, userId, questId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (qu *questUsecase) JoinQuest(ctx context.Context, userId uint, questId uint, token string) error {
+	if err := qu.qr.JoinQuest(ctx, userId, questId, token); err != nil {
+		if errors.Is(err, repository.ErrQuestFull) {
+			metrics.QuestFullRejections.Inc()
+		}
 		return err
 	}
+	metrics.QuestJoins.Inc()
 	return nil
 }
 
-func (qu *questUsecase) DeleteQuest(userId uint, questId uint) error {
-	if err := qu.qr.DeleteQuest(userId, questId); err != nil {
+func (qu *questUsecase) CancelQuest(ctx context.Context, userId uint, questId uint) error {
+	if err := qu.qr.CancelQuest(ctx, userId, questId); err != nil {
 		return err
 	}
+	metrics.QuestCancels.Inc()
 	return nil
 }
 
-func (qu *questUsecase) JoinQuest(userId uint, questId uint) error {
-	if err := qu.qr.JoinQuest(userId, questId); err != nil {
+func (qu *questUsecase) GetJoinRequests(ctx context.Context, userId uint, questId uint) ([]model.JoinRequestResponse, error) {
+	participants := []model.QuestParticipant{}
+	if err := qu.qr.GetJoinRequests(ctx, &participants, userId, questId); err != nil {
+		return nil, err
+	}
+	resRequests := []model.JoinRequestResponse{}
+	for _, p := range participants {
+		resRequests = append(resRequests, model.JoinRequestResponse{
+			UserId:   p.UserId,
+			UserName: p.User.UserName,
+			Status:   p.Status,
+			Message:  p.Message,
+			JoinedAt: p.JoinedAt,
+		})
+	}
+	return resRequests, nil
+}
+
+func (qu *questUsecase) ApproveJoinRequest(ctx context.Context, userId uint, questId uint, participantId uint, message string) error {
+	// 承認とお知らせの作成を1つのトランザクションで行う（お知らせだけ失敗して承認が残ることがないようにする）
+	err := qu.tm.Do(ctx, func(ctx context.Context) error {
+		if err := qu.qr.DecideJoinRequest(ctx, userId, questId, participantId, model.ParticipantApproved, message); err != nil {
+			return err
+		}
+		return qu.notifyParticipant(ctx, userId, questId, participantId, "への参加が承認されました", message)
+	})
+	if errors.Is(err, repository.ErrQuestFull) {
+		metrics.QuestFullRejections.Inc()
+	}
+	return err
+}
+
+func (qu *questUsecase) RejectJoinRequest(ctx context.Context, userId uint, questId uint, participantId uint, message string) error {
+	return qu.tm.Do(ctx, func(ctx context.Context) error {
+		if err := qu.qr.DecideJoinRequest(ctx, userId, questId, participantId, model.ParticipantRejected, message); err != nil {
+			return err
+		}
+		return qu.notifyParticipant(ctx, userId, questId, participantId, "への参加申請が却下されました", message)
+	})
+}
+
+func (qu *questUsecase) RemoveParticipant(ctx context.Context, userId uint, questId uint, participantId uint, reason string) error {
+	return qu.tm.Do(ctx, func(ctx context.Context) error {
+		if err := qu.qr.RemoveParticipant(ctx, userId, questId, participantId); err != nil {
+			return err
+		}
+		return qu.notifyParticipant(ctx, userId, questId, participantId, "の参加者から外されました", reason)
+	})
+}
+
+func (qu *questUsecase) BanParticipant(ctx context.Context, userId uint, questId uint, participantId uint, reason string) error {
+	return qu.tm.Do(ctx, func(ctx context.Context) error {
+		if err := qu.qr.BanParticipant(ctx, userId, questId, participantId, reason); err != nil {
+			return err
+		}
+		return qu.notifyParticipant(ctx, userId, questId, participantId, "への参加が禁止されました", reason)
+	})
+}
+
+/* 募集主の操作を参加者にお知らせする（理由・メッセージは任意） */
+func (qu *questUsecase) notifyParticipant(ctx context.Context, userId uint, questId uint, participantId uint, event string, message string) error {
+	quest := model.Quest{}
+	if err := qu.qr.GetQuestById(ctx, &quest, userId, questId); err != nil {
+		return err
+	}
+	text := fmt.Sprintf("クエスト「%s」%s", quest.Title, event)
+	if message != "" {
+		text += fmt.Sprintf("（%s）", message)
+	}
+	notification := model.Notification{Message: text, UserId: participantId}
+	if err := qu.nr.CreateNotification(ctx, &notification); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (qu *questUsecase) CancelQuest(userId uint, questId uint) error {
-	if err := qu.qr.CancelQuest(userId, questId); err != nil {
+func (qu *questUsecase) CreateInvite(ctx context.Context, userId uint, questId uint, req model.CreateInviteRequest) (model.QuestInviteResponse, error) {
+	// 推測されないランダムなトークンを作成
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return model.QuestInviteResponse{}, err
+	}
+	invite := model.QuestInvite{
+		Token:   hex.EncodeToString(b),
+		MaxUses: req.MaxUses,
+		QuestId: questId,
+	}
+	if req.ExpiresAt != nil {
+		invite.ExpiresAt = *req.ExpiresAt
+	}
+	if err := qu.qr.CreateInvite(ctx, &invite, userId); err != nil {
+		return model.QuestInviteResponse{}, err
+	}
+	resInvite := model.QuestInviteResponse{
+		Token:     invite.Token,
+		ExpiresAt: nilIfZero(invite.ExpiresAt),
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+		CreatedAt: invite.CreatedAt,
+	}
+	return resInvite, nil
+}
+
+func (qu *questUsecase) GetInvites(ctx context.Context, userId uint, questId uint) ([]model.QuestInviteResponse, error) {
+	invites := []model.QuestInvite{}
+	if err := qu.qr.GetInvites(ctx, &invites, userId, questId); err != nil {
+		return nil, err
+	}
+	resInvites := []model.QuestInviteResponse{}
+	for _, invite := range invites {
+		resInvites = append(resInvites, model.QuestInviteResponse{
+			Token:     invite.Token,
+			ExpiresAt: nilIfZero(invite.ExpiresAt),
+			MaxUses:   invite.MaxUses,
+			Uses:      invite.Uses,
+			CreatedAt: invite.CreatedAt,
+		})
+	}
+	return resInvites, nil
+}
+
+func (qu *questUsecase) DeleteInvite(ctx context.Context, userId uint, questId uint, token string) error {
+	if err := qu.qr.DeleteInvite(ctx, userId, questId, token); err != nil {
 		return err
 	}
 	return nil
 }
+
+func (qu *questUsecase) GetQuestsStamp(ctx context.Context) (model.QuestStamp, error) {
+	stamp := model.QuestStamp{}
+	if err := qu.qr.GetQuestsStamp(ctx, &stamp); err != nil {
+		return model.QuestStamp{}, err
+	}
+	return stamp, nil
+}
+
+func (qu *questUsecase) GetQuestStamp(ctx context.Context, questId uint) (model.QuestStamp, error) {
+	stamp := model.QuestStamp{}
+	if err := qu.qr.GetQuestStamp(ctx, &stamp, questId); err != nil {
+		return model.QuestStamp{}, err
+	}
+	return stamp, nil
+}
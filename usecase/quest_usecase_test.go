@@ -0,0 +1,466 @@
+package usecase
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestCreateQuest(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	owner := e.createUser(t, "owner")
+	categoryId := uint(1)
+	e.cr.categories[categoryId] = model.Category{ID: categoryId, Name: "勉強", Fields: []model.CategoryField{
+		{Key: "place", Label: "場所", Type: model.FieldTypeText, Required: true},
+	}}
+	start := time.Date(2030, 4, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		quest    model.Quest
+		wantErr  error // nilでなければerrors.Isで比較する
+		wantFail bool
+		wantTags []string
+	}{
+		{name: "タグの指定なし（カテゴリをタグにする）", quest: model.Quest{Title: "勉強会", Category: "勉強"}, wantTags: []string{"勉強"}},
+		{name: "同じタグになる名前はまとめる", quest: model.Quest{Title: "勉強会", TagNames: []string{"Go", "ｇｏ", " go "}}, wantTags: []string{"Go"}},
+		{name: "追加項目あり", quest: model.Quest{Title: "勉強会", CategoryId: &categoryId, CustomFields: model.CustomFields{"place": "A棟"}}},
+		{name: "タイトルが未入力", quest: model.Quest{Category: "勉強"}, wantFail: true},
+		{name: "公開範囲が不正", quest: model.Quest{Title: "勉強会", Visibility: "secret"}, wantFail: true},
+		{name: "必須の追加項目が未入力", quest: model.Quest{Title: "勉強会", CategoryId: &categoryId}, wantFail: true},
+		{name: "存在しないカテゴリ", quest: model.Quest{Title: "勉強会", CategoryId: new(uint)}, wantErr: gorm.ErrRecordNotFound},
+		{name: "開始が終了より後", quest: model.Quest{Title: "勉強会", StartTime: start, EndTime: start.Add(-time.Hour)}, wantErr: repository.ErrInvalidQuestTimes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quest := tt.quest
+			quest.UserId = owner.ID
+			before, err := e.qu.GetUserQuests(ctx, owner.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = e.qu.CreateQuest(ctx, quest)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateQuest() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantFail:
+				if err == nil {
+					t.Fatal("CreateQuest() error = nil, want error")
+				}
+			case err != nil:
+				t.Fatalf("CreateQuest() error = %v", err)
+			}
+
+			after, err := e.qu.GetUserQuests(ctx, owner.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != nil || tt.wantFail {
+				if len(after) != len(before) {
+					t.Fatalf("失敗したのにクエストが作成された（%d件 -> %d件）", len(before), len(after))
+				}
+				return
+			}
+			if len(after) != len(before)+1 {
+				t.Fatalf("クエストが作成されていない（%d件 -> %d件）", len(before), len(after))
+			}
+			if tt.wantTags != nil && !slices.Equal(after[0].Tags, tt.wantTags) {
+				t.Errorf("Tags = %v, want %v", after[0].Tags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestUpdateQuest(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	owner := e.createUser(t, "owner")
+	other := e.createUser(t, "other")
+	quest := e.createQuest(t, model.Quest{Title: "勉強会", UserId: owner.ID})
+
+	tests := []struct {
+		name    string
+		userId  uint
+		questId uint
+		title   string
+		wantErr bool
+	}{
+		{"募集主", owner.ID, quest.ID, "読書会", false},
+		{"募集主以外", other.ID, quest.ID, "乗っ取り", true},
+		{"存在しないクエスト", owner.ID, quest.ID + 100, "読書会", true},
+		{"タイトルが未入力", owner.ID, quest.ID, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := e.qu.GetQuestForView(ctx, owner.ID, quest.ID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = e.qu.UpdateQuest(ctx, model.Quest{Title: tt.title}, tt.userId, tt.questId)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateQuest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			after, err := e.qu.GetQuestForView(ctx, owner.ID, quest.ID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := before.Title
+			if !tt.wantErr {
+				want = tt.title
+			}
+			if after.Title != want {
+				t.Errorf("Title = %q, want %q", after.Title, want)
+			}
+		})
+	}
+}
+
+// カテゴリと追加項目の変更も保存される
+func TestPatchQuest_CategoryAndCustomFields(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	owner := e.createUser(t, "owner")
+	e.cr.categories[1] = model.Category{ID: 1, Name: "勉強会"}
+	e.cr.categories[2] = model.Category{ID: 2, Name: "スポーツ", Fields: []model.CategoryField{
+		{Key: "level", Label: "レベル", Type: model.FieldTypeText, Required: true},
+		{Key: "players", Label: "人数", Type: model.FieldTypeNumber},
+	}}
+	categoryId := uint(1)
+	quest := e.createQuest(t, model.Quest{Title: "練習", UserId: owner.ID, CategoryId: &categoryId})
+
+	patch := `{"category_id":2,"custom_fields":{"level":"初心者","players":4}}`
+	if err := e.qu.PatchQuest(ctx, []byte(patch), owner.ID, quest.ID); err != nil {
+		t.Fatalf("PatchQuest() error = %v", err)
+	}
+	got, err := e.qu.GetQuestById(ctx, owner.ID, quest.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CategoryId == nil || *got.CategoryId != 2 {
+		t.Errorf("CategoryId = %v, want 2", got.CategoryId)
+	}
+	want := model.CustomFields{"level": "初心者", "players": float64(4)}
+	if !reflect.DeepEqual(got.CustomFields, want) {
+		t.Errorf("CustomFields = %v, want %v", got.CustomFields, want)
+	}
+
+	// nullでカテゴリを外すと追加項目も一緒に消せる
+	if err := e.qu.PatchQuest(ctx, []byte(`{"category_id":null,"custom_fields":null}`), owner.ID, quest.ID); err != nil {
+		t.Fatalf("PatchQuest() error = %v", err)
+	}
+	got, err = e.qu.GetQuestById(ctx, owner.ID, quest.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CategoryId != nil || len(got.CustomFields) != 0 {
+		t.Errorf("CategoryId = %v, CustomFields = %v, want nil, empty", got.CategoryId, got.CustomFields)
+	}
+}
+
+func TestJoinQuest(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	owner := e.createUser(t, "owner")
+	member := e.createUser(t, "member")
+	public := e.createQuest(t, model.Quest{Title: "公開", UserId: owner.ID})
+	approval := e.createQuest(t, model.Quest{Title: "承認制", UserId: owner.ID, RequiresApproval: true})
+	full := e.createQuest(t, model.Quest{Title: "定員1人", UserId: owner.ID, MaxParticipants: 1})
+	inviteOnly := e.createQuest(t, model.Quest{Title: "招待制", UserId: owner.ID, Visibility: model.VisibilityInviteOnly})
+	banned := e.createQuest(t, model.Quest{Title: "参加禁止", UserId: owner.ID})
+
+	if err := e.qu.JoinQuest(ctx, owner.ID, full.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.qu.BanParticipant(ctx, owner.ID, banned.ID, member.ID, "迷惑行為"); err != nil {
+		t.Fatal(err)
+	}
+	invite, err := e.qu.CreateInvite(ctx, owner.ID, inviteOnly.ID, model.CreateInviteRequest{MaxUses: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		questId    uint
+		token      string
+		wantErr    error
+		wantFail   bool
+		wantStatus string
+	}{
+		{name: "公開クエスト", questId: public.ID, wantStatus: model.ParticipantApproved},
+		{name: "2回目の参加は何もしない", questId: public.ID, wantStatus: model.ParticipantApproved},
+		{name: "承認制のクエスト", questId: approval.ID, wantStatus: model.ParticipantPending},
+		{name: "定員に達している", questId: full.ID, wantErr: repository.ErrQuestFull},
+		{name: "招待制でトークンなし", questId: inviteOnly.ID, wantFail: true},
+		{name: "招待制で不正なトークン", questId: inviteOnly.ID, token: "invalid", wantFail: true},
+		{name: "招待制で有効なトークン", questId: inviteOnly.ID, token: invite.Token, wantStatus: model.ParticipantApproved},
+		{name: "参加禁止", questId: banned.ID, wantFail: true},
+		{name: "存在しないクエスト", questId: 999, wantErr: gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.qu.JoinQuest(ctx, member.ID, tt.questId, tt.token)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("JoinQuest() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantFail:
+				if err == nil {
+					t.Fatal("JoinQuest() error = nil, want error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("JoinQuest() error = %v", err)
+			}
+			quest, err := e.qu.GetQuestForView(ctx, member.ID, tt.questId, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if quest.JoinStatus != tt.wantStatus || !quest.Joined {
+				t.Errorf("JoinStatus = %q, Joined = %v, want %q", quest.JoinStatus, quest.Joined, tt.wantStatus)
+			}
+		})
+	}
+
+	// 使用回数の上限に達した招待トークンは使えない
+	third := e.createUser(t, "third")
+	if err := e.qu.JoinQuest(ctx, third.ID, inviteOnly.ID, invite.Token); err == nil {
+		t.Error("使用回数の上限に達した招待トークンで参加できた")
+	}
+}
+
+func TestDecideJoinRequest(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		approve    bool
+		byOther    bool // 募集主以外が操作する
+		fill       bool // 先に定員を埋めておく
+		wantErr    error
+		wantStatus string
+	}{
+		{name: "承認", approve: true, wantStatus: model.ParticipantApproved},
+		{name: "却下", approve: false, wantStatus: model.ParticipantRejected},
+		{name: "募集主以外は承認できない", approve: true, byOther: true, wantErr: gorm.ErrRecordNotFound, wantStatus: model.ParticipantPending},
+		{name: "定員に達している", approve: true, fill: true, wantErr: repository.ErrQuestFull, wantStatus: model.ParticipantPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			owner := e.createUser(t, "owner")
+			member := e.createUser(t, "member")
+			other := e.createUser(t, "other")
+			quest := e.createQuest(t, model.Quest{Title: "承認制", UserId: owner.ID, RequiresApproval: true, MaxParticipants: 1})
+			if err := e.qu.JoinQuest(ctx, member.ID, quest.ID, ""); err != nil {
+				t.Fatal(err)
+			}
+			if tt.fill {
+				if err := e.qu.JoinQuest(ctx, other.ID, quest.ID, ""); err != nil {
+					t.Fatal(err)
+				}
+				if err := e.qu.ApproveJoinRequest(ctx, owner.ID, quest.ID, other.ID, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			actor := owner.ID
+			if tt.byOther {
+				actor = other.ID
+			}
+			var err error
+			if tt.approve {
+				err = e.qu.ApproveJoinRequest(ctx, actor, quest.ID, member.ID, "ようこそ")
+			} else {
+				err = e.qu.RejectJoinRequest(ctx, actor, quest.ID, member.ID, "満員です")
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			view, err := e.qu.GetQuestForView(ctx, member.ID, quest.ID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if view.JoinStatus != tt.wantStatus {
+				t.Errorf("JoinStatus = %q, want %q", view.JoinStatus, tt.wantStatus)
+			}
+			// 処理が成功した場合だけお知らせが届く
+			notifications, err := e.uu.GetNotifications(ctx, member.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool]int{true: 0, false: 1}[tt.wantErr != nil]; len(notifications) != want {
+				t.Errorf("お知らせの件数 = %d, want %d", len(notifications), want)
+			}
+		})
+	}
+}
+
+func TestGetQuestForView(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	owner := e.createUser(t, "owner")
+	member := e.createUser(t, "member")
+	stranger := e.createUser(t, "stranger")
+	inviteOnly := e.createQuest(t, model.Quest{Title: "招待制", UserId: owner.ID, Visibility: model.VisibilityInviteOnly})
+	unlisted := e.createQuest(t, model.Quest{Title: "限定公開", UserId: owner.ID, Visibility: model.VisibilityUnlisted})
+	other := e.createQuest(t, model.Quest{Title: "別の招待制", UserId: owner.ID, Visibility: model.VisibilityInviteOnly})
+	invite, err := e.qu.CreateInvite(ctx, owner.ID, inviteOnly.ID, model.CreateInviteRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.qu.JoinQuest(ctx, member.ID, inviteOnly.ID, invite.Token); err != nil {
+		t.Fatal(err)
+	}
+	// 部外者に送った1回限りの招待（まだ使っていない）
+	pending, err := e.qu.CreateInvite(ctx, owner.ID, inviteOnly.ID, model.CreateInviteRequest{MaxUses: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	expired, err := e.qu.CreateInvite(ctx, owner.ID, inviteOnly.ID, model.CreateInviteRequest{ExpiresAt: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		userId  uint
+		questId uint
+		token   string
+		wantErr bool
+	}{
+		{"招待制を募集主が見る", owner.ID, inviteOnly.ID, "", false},
+		{"招待制を参加者が見る", member.ID, inviteOnly.ID, "", false},
+		{"招待制を部外者が見る", stranger.ID, inviteOnly.ID, "", true},
+		{"招待制を招待された部外者が見る", stranger.ID, inviteOnly.ID, pending.Token, false},
+		{"招待制を不正なトークンで見る", stranger.ID, inviteOnly.ID, "invalid", true},
+		{"招待制を期限切れのトークンで見る", stranger.ID, inviteOnly.ID, expired.Token, true},
+		{"他のクエストのトークンは使えない", stranger.ID, other.ID, pending.Token, true},
+		{"限定公開は誰でも見られる", stranger.ID, unlisted.ID, "", false},
+		{"存在しないクエスト", owner.ID, 999, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.qu.GetQuestForView(ctx, tt.userId, tt.questId, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetQuestForView() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			_, err = e.qu.GetParticipants(ctx, tt.userId, tt.questId, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetParticipants() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 見ただけでは招待の使用回数は増えない
+	if err := e.qu.JoinQuest(ctx, stranger.ID, inviteOnly.ID, pending.Token); err != nil {
+		t.Errorf("JoinQuest() error = %v", err)
+	}
+}
+
+func TestGetAllQuests(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	owner := e.createUser(t, "owner")
+	viewer := e.createUser(t, "viewer")
+	for _, quest := range []model.Quest{
+		{Title: "Go勉強会", TagNames: []string{"Go", "勉強"}},
+		{Title: "Rust勉強会", TagNames: []string{"Rust", "勉強"}},
+		{Title: "散歩", TagNames: []string{"運動"}},
+		{Title: "限定公開", TagNames: []string{"Go"}, Visibility: model.VisibilityUnlisted},
+		{Title: "招待制", TagNames: []string{"Go"}, Visibility: model.VisibilityInviteOnly},
+	} {
+		quest.UserId = owner.ID
+		if err := e.qu.CreateQuest(ctx, quest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := e.tr.CreateTagAlias(ctx, "golang", "go"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter model.QuestFilter
+		want   []string // 新しい順
+	}{
+		{"絞り込みなし（公開のみ）", model.QuestFilter{}, []string{"散歩", "Rust勉強会", "Go勉強会"}},
+		{"1つのタグ", model.QuestFilter{Tags: []string{"勉強"}}, []string{"Rust勉強会", "Go勉強会"}},
+		{"いずれかのタグ", model.QuestFilter{Tags: []string{"go", "運動"}}, []string{"散歩", "Go勉強会"}},
+		{"全てのタグ", model.QuestFilter{Tags: []string{"Go", "勉強"}, MatchAll: true}, []string{"Go勉強会"}},
+		{"同じタグの重複（AND）", model.QuestFilter{Tags: []string{"go", "Go", "勉強"}, MatchAll: true}, []string{"Go勉強会"}},
+		{"別名と統合先（AND）", model.QuestFilter{Tags: []string{"golang", "go"}, MatchAll: true}, []string{"Go勉強会"}},
+		{"該当なし", model.QuestFilter{Tags: []string{"料理"}}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quests, err := e.qu.GetAllQuests(ctx, viewer.ID, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			titles := []string{}
+			for _, quest := range quests {
+				titles = append(titles, quest.Title)
+			}
+			if !slices.Equal(titles, tt.want) {
+				t.Errorf("titles = %v, want %v", titles, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAllQuests_Participants(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	owner := e.createUser(t, "owner")
+	quest := e.createQuest(t, model.Quest{Title: "大人数", UserId: owner.ID})
+	names := []string{"u1", "u2", "u3", "u4", "u5", "u6", "u7"}
+	var last model.User
+	for _, name := range names {
+		last = e.createUser(t, name)
+		if err := e.qu.JoinQuest(ctx, last.ID, quest.ID, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	quests, err := e.qu.GetAllQuests(ctx, last.ID, model.QuestFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quests) != 1 {
+		t.Fatalf("len(quests) = %d, want 1", len(quests))
+	}
+	got := quests[0]
+	if got.ParticipantCount != int64(len(names)) {
+		t.Errorf("ParticipantCount = %d, want %d", got.ParticipantCount, len(names))
+	}
+	if len(got.Participants) != model.ParticipantPreviewLimit {
+		t.Errorf("len(Participants) = %d, want %d", len(got.Participants), model.ParticipantPreviewLimit)
+	}
+	if !got.Joined || got.UserName != owner.UserName {
+		t.Errorf("Joined = %v, UserName = %q", got.Joined, got.UserName)
+	}
+
+	participants, err := e.qu.GetParticipants(ctx, owner.ID, quest.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(participants) != len(names) || participants[0].UserName != names[0] {
+		t.Errorf("participants = %+v", participants)
+	}
+}
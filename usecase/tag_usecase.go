@@ -0,0 +1,92 @@
+package usecase
+
+/* タグに関連するビジネスロジックを実装する部分 */
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type ITagUsecase interface {
+	AutocompleteTags(ctx context.Context, prefix string) ([]model.TagResponse, error)
+	GetPopularTags(ctx context.Context, limit int) ([]model.TagResponse, error)
+	CreateTagAlias(ctx context.Context, alias string, tag string) error
+}
+
+type tagUsecase struct {
+	tr repository.ITagRepository
+}
+
+func NewTagUsecase(tr repository.ITagRepository) ITagUsecase {
+	return &tagUsecase{tr}
+}
+
+/*
+* タグ名を正規化してslugにするヘルパー関数normalizeTagSlug
+* 前後の空白を除き、全角英数字を半角に・英字を小文字にして、空白や_を-にまとめる
+ */
+func normalizeTagSlug(name string) string {
+	var b strings.Builder
+	separator := false
+	for _, r := range strings.TrimSpace(name) {
+		if r >= '！' && r <= '～' { // 全角ASCII -> 半角
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) || r == '_' || r == '-' {
+			separator = true
+			continue
+		}
+		if separator && b.Len() > 0 {
+			b.WriteRune('-')
+		}
+		separator = false
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func (tu *tagUsecase) AutocompleteTags(ctx context.Context, prefix string) ([]model.TagResponse, error) {
+	slug := normalizeTagSlug(prefix)
+	if slug == "" {
+		return []model.TagResponse{}, nil
+	}
+	tags := []model.Tag{}
+	if err := tu.tr.SearchTags(ctx, &tags, slug, 10); err != nil {
+		return nil, err
+	}
+	resTags := []model.TagResponse{}
+	for _, tag := range tags {
+		resTags = append(resTags, model.TagResponse{Name: tag.Name, Slug: tag.Slug})
+	}
+	return resTags, nil
+}
+
+func (tu *tagUsecase) GetPopularTags(ctx context.Context, limit int) ([]model.TagResponse, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+	resTags := []model.TagResponse{}
+	if err := tu.tr.GetPopularTags(ctx, &resTags, limit); err != nil {
+		return nil, err
+	}
+	return resTags, nil
+}
+
+func (tu *tagUsecase) CreateTagAlias(ctx context.Context, alias string, tag string) error {
+	aliasSlug := normalizeTagSlug(alias)
+	tagSlug := normalizeTagSlug(tag)
+	if aliasSlug == "" || tagSlug == "" {
+		return fmt.Errorf("alias and tag are required")
+	}
+	if aliasSlug == tagSlug {
+		return fmt.Errorf("alias must differ from tag")
+	}
+	if err := tu.tr.CreateTagAlias(ctx, aliasSlug, tagSlug); err != nil {
+		return err
+	}
+	return nil
+}
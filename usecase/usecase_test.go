@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"bulletin-board-rest-api/config"
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"bulletin-board-rest-api/repository/memory"
+	"bulletin-board-rest-api/validator"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const testPassword = "password"
+
+// テストごとにメモリ上のリポジトリでusecaseを組み立てる
+type testEnv struct {
+	ur     repository.IUserRepository
+	qr     repository.IQuestRepository
+	nr     repository.INotificationRepository
+	tr     *fakeTagRepository
+	cr     *fakeCategoryRepository
+	mailer *fakeMailer
+	cfg    *config.Config
+	qu     IQuestUsecase
+	uu     IUserUsecase
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	s := memory.NewStore()
+	e := &testEnv{
+		ur:     memory.NewUserRepository(s),
+		qr:     memory.NewQuestRepository(s),
+		nr:     memory.NewNotificationRepository(s),
+		tr:     &fakeTagRepository{tags: map[string]model.Tag{}, aliases: map[string]string{}},
+		cr:     &fakeCategoryRepository{categories: map[uint]model.Category{}},
+		mailer: &fakeMailer{},
+		cfg:    &config.Config{Secret: "test-secret-0123456789abcdef0123456789", FEURL: "http://localhost:3000"},
+	}
+	tm := memory.NewTransactionManager(s)
+	e.qu = NewQuestUsecase(e.qr, e.ur, e.nr, e.tr, e.cr, validator.NewQuestValidator(), tm)
+	e.uu = NewUserUsecase(e.ur, e.qr, e.nr, validator.NewUserValidator(), e.mailer, e.cfg, tm)
+	return e
+}
+
+// パスワードがtestPasswordのユーザーを作成する（サインアップの検証は通さない）
+func (e *testEnv) createUser(t *testing.T, userName string) model.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := model.User{Email: userName + "@example.com", Password: string(hash), UserName: userName}
+	if err := e.ur.CreateUser(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func (e *testEnv) createQuest(t *testing.T, quest model.Quest) model.Quest {
+	t.Helper()
+	if quest.Visibility == "" {
+		quest.Visibility = model.VisibilityPublic
+	}
+	if err := e.qr.CreateQuest(context.Background(), &quest); err != nil {
+		t.Fatal(err)
+	}
+	return quest
+}
+
+//* テスト用の実装
+
+type fakeTagRepository struct {
+	mu      sync.Mutex
+	tags    map[string]model.Tag // slug -> タグ
+	aliases map[string]string    // 別名 -> slug
+}
+
+func (tr *fakeTagRepository) FindOrCreateTag(ctx context.Context, tag *model.Tag, slug string, name string) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if resolved, ok := tr.aliases[slug]; ok {
+		slug = resolved
+	}
+	found, ok := tr.tags[slug]
+	if !ok {
+		found = model.Tag{ID: uint(len(tr.tags) + 1), Name: name, Slug: slug, CreatedAt: time.Now()}
+		tr.tags[slug] = found
+	}
+	*tag = found
+	return nil
+}
+
+func (tr *fakeTagRepository) ResolveSlug(ctx context.Context, slug string) (string, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if resolved, ok := tr.aliases[slug]; ok {
+		return resolved, nil
+	}
+	return slug, nil
+}
+
+func (tr *fakeTagRepository) SearchTags(ctx context.Context, tags *[]model.Tag, prefix string, limit int) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (tr *fakeTagRepository) GetPopularTags(ctx context.Context, tags *[]model.TagResponse, limit int) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (tr *fakeTagRepository) CreateTagAlias(ctx context.Context, aliasSlug string, tagSlug string) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.aliases[aliasSlug] = tagSlug
+	return nil
+}
+
+type fakeCategoryRepository struct {
+	categories map[uint]model.Category
+}
+
+func (cr *fakeCategoryRepository) GetAllCategories(ctx context.Context, categories *[]model.Category) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (cr *fakeCategoryRepository) GetCategoryById(ctx context.Context, category *model.Category, categoryId uint) error {
+	found, ok := cr.categories[categoryId]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	*category = found
+	return nil
+}
+
+func (cr *fakeCategoryRepository) CreateCategory(ctx context.Context, category *model.Category) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (cr *fakeCategoryRepository) UpdateCategory(ctx context.Context, category *model.Category, categoryId uint) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (cr *fakeCategoryRepository) DeleteCategory(ctx context.Context, categoryId uint) error {
+	return fmt.Errorf("not implemented")
+}
+
+// 送信したメールを記録する（errを設定すると送信に失敗する）
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+	err  error
+}
+
+type sentMail struct {
+	to      string
+	subject string
+	body    string
+}
+
+func (m *fakeMailer) Send(to string, subject string, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentMail{to, subject, body})
+	return nil
+}
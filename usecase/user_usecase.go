@@ -3,34 +3,60 @@ package usecase
 /* クエストに関連するビジネスロジックを実装する部分 */
 
 import (
+	"bulletin-board-rest-api/config"
+	"bulletin-board-rest-api/mailer"
+	"bulletin-board-rest-api/metrics"
 	"bulletin-board-rest-api/model"
 	"bulletin-board-rest-api/repository"
 	"bulletin-board-rest-api/validator"
-	"os"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type IUserUsecase interface {
-	SignUp(user model.User) (model.UserResponse, error)
-	Login(user model.User) (string, error) //JWTを返すためにstring型
-	GetUserName(userId uint) (string, error)
-	GetUserInfo(userId uint) (model.UserResponse, error)
-	UpdateUserName(userId uint, userName string) error
+	SignUp(ctx context.Context, user model.User) (model.UserResponse, error)
+	Login(ctx context.Context, user model.User) (string, error) //JWTを返すためにstring型
+	GetUserName(ctx context.Context, userId uint) (string, error)
+	GetUserInfo(ctx context.Context, userId uint) (model.UserResponse, error)
+	UpdateUserName(ctx context.Context, userId uint, userName string) error
+	GetNotifications(ctx context.Context, userId uint) ([]model.NotificationResponse, error)
+	MarkNotificationsAsRead(ctx context.Context, userId uint) error
+	IsAdmin(ctx context.Context, userId uint) (bool, error)
+	UpdateProfile(ctx context.Context, userId uint, profile model.UpdateProfileRequest) error
+	GetPublicProfile(ctx context.Context, userId uint) (model.PublicProfileResponse, error)
+	GetPublicProfileByUserName(ctx context.Context, userName string) (model.PublicProfileResponse, error)
+	DeleteAccount(ctx context.Context, userId uint, req model.DeleteAccountRequest) error
+	ChangePassword(ctx context.Context, userId uint, req model.ChangePasswordRequest) (string, error) // このセッション用の新しいJWTを返す
+	ChangeEmail(ctx context.Context, userId uint, req model.ChangeEmailRequest) error                 // 確認メールを送る（確認後に切り替わる）
+	VerifyEmail(ctx context.Context, token string) error
+	IsSessionActive(ctx context.Context, userId uint, tokenVersion uint) (bool, error)
 }
 
 type userUsecase struct {
-	ur repository.IUserRepository
-	uv validator.IUserValidator
+	ur  repository.IUserRepository
+	qr  repository.IQuestRepository
+	nr  repository.INotificationRepository
+	uv  validator.IUserValidator
+	m   mailer.IMailer
+	cfg *config.Config
+	tm  repository.ITransactionManager
 }
 
-func NewUserUsecase(ur repository.IUserRepository, uv validator.IUserValidator) IUserUsecase {
-	return &userUsecase{ur, uv}
+func NewUserUsecase(ur repository.IUserRepository, qr repository.IQuestRepository, nr repository.INotificationRepository, uv validator.IUserValidator, m mailer.IMailer, cfg *config.Config, tm repository.ITransactionManager) IUserUsecase {
+	return &userUsecase{ur, qr, nr, uv, m, cfg, tm}
 }
 
-func (uu *userUsecase) SignUp(user model.User) (model.UserResponse, error) {
+func (uu *userUsecase) SignUp(ctx context.Context, user model.User) (model.UserResponse, error) {
 	if err := uu.uv.ValidateUserSignUp(user); err != nil {
 		return model.UserResponse{}, err
 	}
@@ -40,7 +66,7 @@ func (uu *userUsecase) SignUp(user model.User) (model.UserResponse, error) {
 		return model.UserResponse{}, err
 	}
 	newUser := model.User{Email: user.Email, Password: string(hash), UserName: user.UserName} //ハッシュ化したパスワードをnewUserに格納
-	if err := uu.ur.CreateUser(&newUser); err != nil {                                        //引数のnewUserをDBに保存
+	if err := uu.ur.CreateUser(ctx, &newUser); err != nil {                                   //引数のnewUserをDBに保存
 		return model.UserResponse{}, err
 	}
 	resUser := model.UserResponse{ //レスポンス用のUserResponse型の変数を作成
@@ -51,43 +77,56 @@ func (uu *userUsecase) SignUp(user model.User) (model.UserResponse, error) {
 	return resUser, nil
 }
 
-func (uu *userUsecase) Login(user model.User) (string, error) {
+func (uu *userUsecase) Login(ctx context.Context, user model.User) (string, error) {
 	if err := uu.uv.ValidateUserLogIn(user); err != nil {
 		return "", err //Loginメソッドの戻り値に適するように空の文字列とエラーを返す
 	}
 	//クライアントからのEmailがDB内に存在するかを確認
 	storedUser := model.User{} //DBから取得したユーザー情報を格納するための変数
-	if err := uu.ur.GetUserByEmail(&storedUser, user.Email); err != nil {
-		return "", err
+	if err := uu.ur.GetUserByEmail(ctx, &storedUser, user.Email); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err // DBの障害などはログイン失敗として数えない
+		}
+		metrics.LoginFailures.Inc()
+		return "", ErrInvalidCredentials
 	}
 	// ハッシュ化されたパスと元のパスの一致を比較
 	err := bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(user.Password))
 	if err != nil {
-		return "", err
+		metrics.LoginFailures.Inc()
+		return "", ErrInvalidCredentials
 	}
-	// JWTトークンの作成
+	if storedUser.Suspended { // 利用停止中のアカウント
+		return "", fmt.Errorf("account is suspended")
+	}
+	return uu.issueToken(ctx, storedUser) //JWTを返す
+}
+
+/* JWTトークンの作成（ver はパスワード変更などで古いトークンを無効にするために使う） */
+func (uu *userUsecase) issueToken(ctx context.Context, user model.User) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{ //JWTの生成
-		"user_id": storedUser.ID,                             //ユーザーIDの設定
+		"user_id": user.ID,                                   //ユーザーIDの設定
+		"ver":     user.TokenVersion,                         //トークンのバージョン
 		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), //TODO: 有効期限の設定
 	})
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET"))) //著名済みの文字列を生成 <- トークンとして使うことで、信頼性↑
+	tokenString, err := token.SignedString([]byte(uu.cfg.Secret)) //著名済みの文字列を生成 <- トークンとして使うことで、信頼性↑
 	if err != nil {
 		return "", err
 	}
-	return tokenString, nil //JWTを返す
+	return tokenString, nil
 }
 
-func (uu *userUsecase) GetUserName(userId uint) (string, error) {
+func (uu *userUsecase) GetUserName(ctx context.Context, userId uint) (string, error) {
 	User := model.User{}
-	if err := uu.ur.GetUserByID(&User, userId); err != nil {
+	if err := uu.ur.GetUserByID(ctx, &User, userId); err != nil {
 		return "", err
 	}
 	return User.UserName, nil
 }
 
-func (uu *userUsecase) GetUserInfo(userId uint) (model.UserResponse, error) {
+func (uu *userUsecase) GetUserInfo(ctx context.Context, userId uint) (model.UserResponse, error) {
 	User := model.User{}
-	if err := uu.ur.GetUserByID(&User, userId); err != nil {
+	if err := uu.ur.GetUserByID(ctx, &User, userId); err != nil {
 		return model.UserResponse{}, err
 	}
 
@@ -98,9 +137,269 @@ func (uu *userUsecase) GetUserInfo(userId uint) (model.UserResponse, error) {
 	return resUser, nil
 }
 
-func (uu *userUsecase) UpdateUserName(userId uint, userName string) error {
-	if err := uu.ur.UpdateUserName(userId, userName); err != nil {
+func (uu *userUsecase) UpdateUserName(ctx context.Context, userId uint, userName string) error {
+	if err := uu.ur.UpdateUserName(ctx, userId, userName); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (uu *userUsecase) GetNotifications(ctx context.Context, userId uint) ([]model.NotificationResponse, error) {
+	notifications := []model.Notification{}
+	if err := uu.nr.GetNotifications(ctx, &notifications, userId); err != nil {
+		return nil, err
+	}
+	resNotifications := []model.NotificationResponse{}
+	for _, n := range notifications {
+		resNotifications = append(resNotifications, model.NotificationResponse{
+			ID:        n.ID,
+			Message:   n.Message,
+			Read:      n.Read,
+			CreatedAt: n.CreatedAt,
+		})
+	}
+	return resNotifications, nil
+}
+
+func (uu *userUsecase) MarkNotificationsAsRead(ctx context.Context, userId uint) error {
+	if err := uu.nr.MarkAsRead(ctx, userId); err != nil {
 		return err
 	}
 	return nil
 }
+
+func (uu *userUsecase) IsAdmin(ctx context.Context, userId uint) (bool, error) {
+	User := model.User{}
+	if err := uu.ur.GetUserByID(ctx, &User, userId); err != nil {
+		return false, err
+	}
+	return User.IsAdmin, nil
+}
+
+func (uu *userUsecase) UpdateProfile(ctx context.Context, userId uint, profile model.UpdateProfileRequest) error {
+	if err := uu.uv.ValidateUserProfile(profile); err != nil {
+		return err
+	}
+	if err := uu.ur.UpdateProfile(ctx, userId, profile); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (uu *userUsecase) GetPublicProfile(ctx context.Context, userId uint) (model.PublicProfileResponse, error) {
+	User := model.User{}
+	if err := uu.ur.GetUserByID(ctx, &User, userId); err != nil {
+		return model.PublicProfileResponse{}, err
+	}
+	return uu.publicProfile(ctx, User)
+}
+
+func (uu *userUsecase) GetPublicProfileByUserName(ctx context.Context, userName string) (model.PublicProfileResponse, error) {
+	User := model.User{}
+	if err := uu.ur.GetUserByUserName(ctx, &User, userName); err != nil {
+		return model.PublicProfileResponse{}, err
+	}
+	return uu.publicProfile(ctx, User)
+}
+
+/* プロフィールと公開クエストをまとめる（メールアドレスは含めない） */
+func (uu *userUsecase) publicProfile(ctx context.Context, User model.User) (model.PublicProfileResponse, error) {
+	created := []model.QuestSummary{}
+	if err := uu.qr.GetPublicUserQuestsFromDB(ctx, &created, User.ID); err != nil {
+		return model.PublicProfileResponse{}, err
+	}
+	joined := []model.QuestSummary{}
+	if err := uu.qr.GetPublicJoinedQuestsFromDB(ctx, &joined, User.ID); err != nil {
+		return model.PublicProfileResponse{}, err
+	}
+
+	resProfile := model.PublicProfileResponse{
+		ID:            User.ID,
+		UserName:      User.UserName,
+		DisplayName:   User.DisplayName,
+		Bio:           User.Bio,
+		Faculty:       User.Faculty,
+		Grade:         User.Grade,
+		Avatar:        User.Avatar,
+		Links:         append([]string{}, User.Links...),
+		CreatedQuests: toQuestResponses(created),
+		JoinedQuests:  toQuestResponses(joined),
+	}
+	return resProfile, nil
+}
+
+func (uu *userUsecase) DeleteAccount(ctx context.Context, userId uint, req model.DeleteAccountRequest) error {
+	// パスワードで本人確認
+	User := model.User{}
+	if err := uu.ur.GetUserByID(ctx, &User, userId); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(User.Password), []byte(req.Password)); err != nil {
+		return err
+	}
+
+	if req.QuestMode != model.QuestModeDelete && req.QuestMode != model.QuestModeTransfer {
+		return fmt.Errorf("quest_mode must be %q or %q", model.QuestModeDelete, model.QuestModeTransfer)
+	}
+
+	// 引き継ぎ先の確認から退会処理までを1つのトランザクションで行う
+	return uu.tm.Do(ctx, func(ctx context.Context) error {
+		// 作成したクエストの扱いを決める
+		var transferTo uint
+		if req.QuestMode == model.QuestModeTransfer {
+			target := model.User{}
+			if err := uu.ur.GetUserByUserName(ctx, &target, req.TransferTo); err != nil {
+				return err
+			}
+			if target.ID == userId {
+				return fmt.Errorf("cannot transfer quests to yourself")
+			}
+			if target.Suspended {
+				return fmt.Errorf("cannot transfer quests to a suspended user")
+			}
+			if isDeletedAccount(target) {
+				return fmt.Errorf("cannot transfer quests to a deleted account")
+			}
+			if err := uu.checkCoOrganizer(ctx, userId, target.ID); err != nil {
+				return err
+			}
+			transferTo = target.ID
+		}
+		return uu.ur.DeleteAccount(ctx, userId, transferTo)
+	})
+}
+
+/* 退会したアカウントはパスワードを空にして匿名化している（ログインできない） */
+func isDeletedAccount(user model.User) bool {
+	return user.Password == ""
+}
+
+/* 引き継ぎ先は、作成した全てのクエストに参加が確定している人（共同主催者）に限る */
+func (uu *userUsecase) checkCoOrganizer(ctx context.Context, userId uint, targetId uint) error {
+	quests := []model.QuestSummary{}
+	if err := uu.qr.GetUserQuestsFromDB(ctx, &quests, userId); err != nil {
+		return err
+	}
+	for _, quest := range quests {
+		participants := []model.QuestParticipant{}
+		if err := uu.qr.GetParticipants(ctx, &participants, quest.ID); err != nil {
+			return err
+		}
+		approved := false
+		for _, p := range participants {
+			if p.UserId == targetId {
+				approved = true
+				break
+			}
+		}
+		if !approved {
+			return fmt.Errorf("transfer target must be an approved participant of quest %d", quest.ID)
+		}
+	}
+	return nil
+}
+
+func (uu *userUsecase) ChangePassword(ctx context.Context, userId uint, req model.ChangePasswordRequest) (string, error) {
+	User := model.User{}
+	if err := uu.ur.GetUserByID(ctx, &User, userId); err != nil {
+		return "", err
+	}
+	// 現在のパスワードで本人確認
+	if err := bcrypt.CompareHashAndPassword([]byte(User.Password), []byte(req.CurrentPassword)); err != nil {
+		return "", err
+	}
+	if err := uu.uv.ValidatePassword(req.NewPassword); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), 10)
+	if err != nil {
+		return "", err
+	}
+	// パスワードを更新し、他のセッションのJWTを無効にする
+	if err := uu.ur.UpdatePassword(ctx, userId, string(hash)); err != nil {
+		return "", err
+	}
+	// 変更は確定しているので、お知らせメールが送れなくてもリクエストは失敗にしない
+	if err := uu.m.Send(User.Email, "パスワードが変更されました",
+		"アカウントのパスワードが変更されました。心当たりがない場合は管理者に連絡してください。"); err != nil {
+		slog.WarnContext(ctx, "failed to send password change notice", "user_id", userId, "error", err)
+	}
+
+	// このセッションは続けて使えるように新しいトークンを発行する
+	if err := uu.ur.GetUserByID(ctx, &User, userId); err != nil {
+		return "", err
+	}
+	return uu.issueToken(ctx, User)
+}
+
+func (uu *userUsecase) ChangeEmail(ctx context.Context, userId uint, req model.ChangeEmailRequest) error {
+	User := model.User{}
+	if err := uu.ur.GetUserByID(ctx, &User, userId); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(User.Password), []byte(req.CurrentPassword)); err != nil {
+		return err
+	}
+	if err := uu.uv.ValidateNewEmail(req.NewEmail); err != nil {
+		return err
+	}
+	// 他のアカウントが使っているアドレスなら、確認メールを送る前に断る（確定時の一意制約違反を防ぐ）
+	if err := uu.ur.GetUserByEmail(ctx, &model.User{}, req.NewEmail); err == nil {
+		return repository.ErrDuplicate
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	// 確認用トークンを作成し、ハッシュだけを保存する
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+	sum := sha256.Sum256([]byte(token))
+	if err := uu.ur.SetPendingEmail(ctx, userId, req.NewEmail, hex.EncodeToString(sum[:]), time.Now().Add(24*time.Hour)); err != nil {
+		return err
+	}
+
+	// 新しいアドレスに確認メール、古いアドレスにお知らせを送る
+	// 申請は保存済みなので、送れなくてもリクエストは失敗にしない（届かなければ申請し直してもらう）
+	link := fmt.Sprintf("%s/verify-email?token=%s", uu.cfg.FEURL, token)
+	if err := uu.m.Send(req.NewEmail, "メールアドレスの確認",
+		"24時間以内に次のリンクを開いてメールアドレスの変更を完了してください。\n"+link); err != nil {
+		slog.WarnContext(ctx, "failed to send email verification", "user_id", userId, "error", err)
+	}
+	if err := uu.m.Send(User.Email, "メールアドレスの変更が申請されました",
+		fmt.Sprintf("メールアドレスを %s に変更する申請がありました。心当たりがない場合は管理者に連絡してください。", req.NewEmail)); err != nil {
+		slog.WarnContext(ctx, "failed to send email change request notice", "user_id", userId, "error", err)
+	}
+	return nil
+}
+
+func (uu *userUsecase) VerifyEmail(ctx context.Context, token string) error {
+	sum := sha256.Sum256([]byte(token))
+	User := model.User{}
+	// トークンの確認と切り替えを1つのトランザクションで行い、通知メールは確定後に送る
+	err := uu.tm.Do(ctx, func(ctx context.Context) error {
+		if err := uu.ur.GetUserByEmailToken(ctx, &User, hex.EncodeToString(sum[:])); err != nil {
+			return err
+		}
+		return uu.ur.ConfirmPendingEmail(ctx, User.ID)
+	})
+	if err != nil {
+		return err
+	}
+	// 変更は確定していてトークンも使用済みなので、お知らせメールが送れなくてもリクエストは失敗にしない
+	if err := uu.m.Send(User.Email, "メールアドレスが変更されました",
+		fmt.Sprintf("アカウントのメールアドレスが %s に変更されました。", User.PendingEmail)); err != nil {
+		slog.WarnContext(ctx, "failed to send email change notice", "user_id", User.ID, "error", err)
+	}
+	return nil
+}
+
+func (uu *userUsecase) IsSessionActive(ctx context.Context, userId uint, tokenVersion uint) (bool, error) {
+	User := model.User{}
+	if err := uu.ur.GetUserByID(ctx, &User, userId); err != nil {
+		return false, err
+	}
+	return User.TokenVersion == tokenVersion && !User.Suspended, nil
+}
@@ -0,0 +1,407 @@
+package usecase
+
+import (
+	"bulletin-board-rest-api/model"
+	"bulletin-board-rest-api/repository"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// is.Emailはドメインの名前解決まで行うので、ネットワークがない環境では学内メールアドレスが通らない
+func skipWithoutDNS(t *testing.T) {
+	t.Helper()
+	if _, err := net.LookupIP("st.pu-toyama.ac.jp"); err != nil {
+		t.Skip("学内メールアドレスのドメインを名前解決できないためスキップ:", err)
+	}
+}
+
+func TestSignUp(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	e.createUser(t, "taken")
+
+	tests := []struct {
+		name    string
+		user    model.User
+		online  bool // 学内メールアドレスの名前解決が必要
+		wantErr bool
+	}{
+		{"学外のメールアドレス", model.User{Email: "taro@example.com", Password: "secret", UserName: "taro"}, false, true},
+		{"パスワードが短い", model.User{Email: "taro@example.com", Password: "abc", UserName: "taro"}, false, true},
+		{"ユーザー名が未入力", model.User{Email: "taro@example.com", Password: "secret"}, false, true},
+		{"正しい入力", model.User{Email: "s0000001@st.pu-toyama.ac.jp", Password: "secret", UserName: "taro"}, true, false},
+		{"ユーザー名が重複", model.User{Email: "s0000002@st.pu-toyama.ac.jp", Password: "secret", UserName: "taken"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.online {
+				skipWithoutDNS(t)
+			}
+			res, err := e.uu.SignUp(ctx, tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SignUp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (res.ID == 0 || res.Email != tt.user.Email) {
+				t.Errorf("SignUp() = %+v", res)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	user := e.createUser(t, "taro")
+	suspended := e.createUser(t, "jiro")
+	if err := e.ur.SetSuspended(ctx, suspended.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+		wantFail bool
+	}{
+		{name: "正しいパスワード", email: user.Email, password: testPassword},
+		{name: "パスワードが違う", email: user.Email, password: "wrong-password", wantErr: ErrInvalidCredentials},
+		{name: "登録されていないメールアドレス", email: "nobody@example.com", password: testPassword, wantErr: ErrInvalidCredentials},
+		{name: "利用停止中", email: suspended.Email, password: testPassword, wantFail: true},
+		{name: "メールアドレスの形式が不正", email: "taro", password: testPassword, wantFail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := e.uu.Login(ctx, model.User{Email: tt.email, Password: tt.password})
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantFail:
+				if err == nil {
+					t.Fatal("Login() error = nil, want error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("Login() error = %v", err)
+			}
+			claims := jwt.MapClaims{}
+			if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+				return []byte(e.cfg.Secret), nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if claims["user_id"].(float64) != float64(user.ID) {
+				t.Errorf("user_id = %v, want %d", claims["user_id"], user.ID)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		current     string
+		newPassword string
+		wantErr     bool
+	}{
+		{"正しい入力", testPassword, "new-password", false},
+		{"現在のパスワードが違う", "wrong-password", "new-password", true},
+		{"新しいパスワードが短い", testPassword, "abc", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			user := e.createUser(t, "taro")
+			_, err := e.uu.ChangePassword(ctx, user.ID, model.ChangePasswordRequest{CurrentPassword: tt.current, NewPassword: tt.newPassword})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ChangePassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// 変更した場合だけ、それまでのJWTが無効になり新しいパスワードでログインできる
+			active, err := e.uu.IsSessionActive(ctx, user.ID, user.TokenVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if active != tt.wantErr {
+				t.Errorf("IsSessionActive() = %v, want %v", active, tt.wantErr)
+			}
+			password := testPassword
+			if !tt.wantErr {
+				password = tt.newPassword
+			}
+			if _, err := e.uu.Login(ctx, model.User{Email: user.Email, Password: password}); err != nil {
+				t.Errorf("Login() error = %v", err)
+			}
+		})
+	}
+}
+
+// お知らせメールが送れなくても変更は成功として扱う
+func TestChangePassword_MailFailure(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	e.mailer.err = errors.New("smtp unavailable")
+	user := e.createUser(t, "taro")
+
+	token, err := e.uu.ChangePassword(ctx, user.ID, model.ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "new-password"})
+	if err != nil {
+		t.Fatalf("ChangePassword() error = %v", err)
+	}
+	if token == "" {
+		t.Error("ChangePassword() token is empty")
+	}
+	if _, err := e.uu.Login(ctx, model.User{Email: user.Email, Password: "new-password"}); err != nil {
+		t.Errorf("Login() error = %v", err)
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	skipWithoutDNS(t)
+	ctx := context.Background()
+	e := newTestEnv(t)
+	user := e.createUser(t, "taro")
+	newEmail := "s0000001@st.pu-toyama.ac.jp"
+
+	if err := e.uu.ChangeEmail(ctx, user.ID, model.ChangeEmailRequest{CurrentPassword: testPassword, NewEmail: newEmail}); err != nil {
+		t.Fatal(err)
+	}
+	// 確認メールのリンクからトークンを取り出す
+	var token string
+	for _, mail := range e.mailer.sent {
+		if mail.to == newEmail {
+			link := mail.body[strings.LastIndex(mail.body, "\n")+1:]
+			u, err := url.Parse(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token = u.Query().Get("token")
+		}
+	}
+	if token == "" {
+		t.Fatal("確認メールが送られていない")
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"不正なトークン", "invalid", true},
+		{"正しいトークン", token, false},
+		{"使用済みのトークン", token, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := e.uu.VerifyEmail(ctx, tt.token); (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyEmail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	info, err := e.uu.GetUserInfo(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Email != newEmail {
+		t.Errorf("Email = %q, want %q", info.Email, newEmail)
+	}
+}
+
+// 他のアカウントが使っているアドレスには確認メールを送らずに断る
+func TestChangeEmail_Taken(t *testing.T) {
+	skipWithoutDNS(t)
+	ctx := context.Background()
+	e := newTestEnv(t)
+	user := e.createUser(t, "taro")
+	other := e.createUser(t, "jiro")
+	if err := e.ur.SetPendingEmail(ctx, other.ID, "s0000001@st.pu-toyama.ac.jp", "hash", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ur.ConfirmPendingEmail(ctx, other.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	err := e.uu.ChangeEmail(ctx, user.ID, model.ChangeEmailRequest{CurrentPassword: testPassword, NewEmail: "s0000001@st.pu-toyama.ac.jp"})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Fatalf("ChangeEmail() error = %v, want %v", err, repository.ErrDuplicate)
+	}
+	if len(e.mailer.sent) != 0 {
+		t.Errorf("len(sent) = %d, want 0", len(e.mailer.sent))
+	}
+}
+
+// 申請の保存後にメールが送れなくても失敗にしない
+func TestChangeEmail_MailFailure(t *testing.T) {
+	skipWithoutDNS(t)
+	ctx := context.Background()
+	e := newTestEnv(t)
+	e.mailer.err = errors.New("smtp unavailable")
+	user := e.createUser(t, "taro")
+
+	if err := e.uu.ChangeEmail(ctx, user.ID, model.ChangeEmailRequest{CurrentPassword: testPassword, NewEmail: "s0000001@st.pu-toyama.ac.jp"}); err != nil {
+		t.Fatalf("ChangeEmail() error = %v", err)
+	}
+	got := model.User{}
+	if err := e.ur.GetUserByID(ctx, &got, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got.PendingEmail != "s0000001@st.pu-toyama.ac.jp" {
+		t.Errorf("PendingEmail = %q", got.PendingEmail)
+	}
+}
+
+// 変更の確定後にお知らせメールが送れなくても失敗にしない（トークンは使用済みで再試行できないため）
+func TestVerifyEmail_MailFailure(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	e.mailer.err = errors.New("smtp unavailable")
+	user := e.createUser(t, "taro")
+	sum := sha256.Sum256([]byte("token"))
+	if err := e.ur.SetPendingEmail(ctx, user.ID, "new@example.com", hex.EncodeToString(sum[:]), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.uu.VerifyEmail(ctx, "token"); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	info, err := e.uu.GetUserInfo(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Email != "new@example.com" {
+		t.Errorf("Email = %q, want %q", info.Email, "new@example.com")
+	}
+}
+
+// 退会前に送った確認リンクでは、退会後のアカウントにメールアドレスを戻せない
+func TestDeleteAccount_PendingEmail(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEnv(t)
+	user := e.createUser(t, "taro")
+	sum := sha256.Sum256([]byte("token"))
+	if err := e.ur.SetPendingEmail(ctx, user.ID, "new@example.com", hex.EncodeToString(sum[:]), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.uu.DeleteAccount(ctx, user.ID, model.DeleteAccountRequest{Password: testPassword, QuestMode: model.QuestModeDelete}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.uu.VerifyEmail(ctx, "token"); err == nil {
+		t.Error("VerifyEmail() error = nil, want error")
+	}
+	got := model.User{}
+	if err := e.ur.GetUserByID(ctx, &got, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != fmt.Sprintf("deleted-%d@invalid", user.ID) || got.PendingEmail != "" || got.EmailToken != "" {
+		t.Errorf("Email = %q, PendingEmail = %q, EmailToken = %q", got.Email, got.PendingEmail, got.EmailToken)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		req        model.DeleteAccountRequest
+		wantErr    error
+		wantFail   bool
+		wantMsg    string // エラーメッセージに含まれる文字列
+		wantQuests int    // 退会後に引き継ぎ先（cohost）が持つクエストの数
+	}{
+		{name: "クエストを削除して退会", req: model.DeleteAccountRequest{Password: testPassword, QuestMode: model.QuestModeDelete}},
+		{name: "クエストを引き継いで退会", req: model.DeleteAccountRequest{Password: testPassword, QuestMode: model.QuestModeTransfer, TransferTo: "cohost"}, wantQuests: 2},
+		{name: "パスワードが違う", req: model.DeleteAccountRequest{Password: "wrong-password", QuestMode: model.QuestModeDelete}, wantFail: true},
+		{name: "クエストの扱いが未指定", req: model.DeleteAccountRequest{Password: testPassword}, wantFail: true},
+		{name: "自分に引き継ぐ", req: model.DeleteAccountRequest{Password: testPassword, QuestMode: model.QuestModeTransfer, TransferTo: "taro"}, wantFail: true},
+		{name: "存在しないユーザーに引き継ぐ", req: model.DeleteAccountRequest{Password: testPassword, QuestMode: model.QuestModeTransfer, TransferTo: "nobody"}, wantErr: gorm.ErrRecordNotFound},
+		{name: "利用停止中のユーザーに引き継ぐ", req: model.DeleteAccountRequest{Password: testPassword, QuestMode: model.QuestModeTransfer, TransferTo: "banned"}, wantFail: true},
+		{name: "一部のクエストにしか参加していないユーザーに引き継ぐ", req: model.DeleteAccountRequest{Password: testPassword, QuestMode: model.QuestModeTransfer, TransferTo: "partial"}, wantFail: true},
+		{name: "参加していないユーザーに引き継ぐ", req: model.DeleteAccountRequest{Password: testPassword, QuestMode: model.QuestModeTransfer, TransferTo: "stranger"}, wantFail: true},
+		{name: "退会済みのユーザーに引き継ぐ", req: model.DeleteAccountRequest{Password: testPassword, QuestMode: model.QuestModeTransfer, TransferTo: "gone"}, wantFail: true, wantMsg: "deleted account"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			user := e.createUser(t, "taro")
+			cohost := e.createUser(t, "cohost")
+			banned := e.createUser(t, "banned")
+			partial := e.createUser(t, "partial")
+			e.createUser(t, "stranger")
+			gone := e.createUser(t, "gone")
+			if err := e.ur.SetSuspended(ctx, banned.ID, true); err != nil {
+				t.Fatal(err)
+			}
+			for i, title := range []string{"勉強会", "読書会"} {
+				quest := e.createQuest(t, model.Quest{Title: title, UserId: user.ID})
+				// 引き継ぎ先は参加者（共同主催者）だが、主催者になるので参加記録は外れる
+				joining := []uint{cohost.ID, banned.ID, gone.ID}
+				if i == 0 {
+					joining = append(joining, partial.ID)
+				}
+				for _, id := range joining {
+					if err := e.qu.JoinQuest(ctx, id, quest.ID, ""); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			// 退会したユーザーは匿名化された名前でしか指定できない
+			if err := e.ur.DeleteAccount(ctx, gone.ID, 0); err != nil {
+				t.Fatal(err)
+			}
+			req := tt.req
+			if req.TransferTo == "gone" {
+				req.TransferTo = fmt.Sprintf("退会済み%d", gone.ID)
+			}
+
+			err := e.uu.DeleteAccount(ctx, user.ID, req)
+			failed := tt.wantErr != nil || tt.wantFail
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DeleteAccount() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantFail:
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Fatalf("DeleteAccount() error = %v, want error containing %q", err, tt.wantMsg)
+				}
+			case err != nil:
+				t.Fatalf("DeleteAccount() error = %v", err)
+			}
+
+			// 失敗した場合は何も変わらない
+			_, err = e.uu.Login(ctx, model.User{Email: user.Email, Password: testPassword})
+			if (err == nil) != failed {
+				t.Errorf("退会後のログイン error = %v", err)
+			}
+			owned, err := e.qu.GetUserQuests(ctx, cohost.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(owned) != tt.wantQuests {
+				t.Errorf("引き継ぎ先のクエスト = %d件, want %d件", len(owned), tt.wantQuests)
+			}
+			joined, err := e.qu.GetJoinedQuests(ctx, cohost.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool]int{true: 2, false: 0}[failed]; len(joined) != want {
+				t.Errorf("引き継ぎ先の参加クエスト = %d件, want %d件", len(joined), want)
+			}
+		})
+	}
+}
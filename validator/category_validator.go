@@ -0,0 +1,59 @@
+package validator
+
+import (
+	"bulletin-board-rest-api/model"
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type ICategoryValidator interface {
+	CategoryValidate(category model.Category) error
+}
+
+type categoryValidator struct{}
+
+func NewCategoryValidator() ICategoryValidator {
+	return &categoryValidator{}
+}
+
+func (cv *categoryValidator) CategoryValidate(category model.Category) error {
+	if err := validation.ValidateStruct(&category,
+		validation.Field(
+			&category.Name,
+			validation.Required.Error("カテゴリ名を入力してください"),
+			validation.RuneLength(1, 20).Error("カテゴリ名は20文字以内で入力してください"),
+		),
+		validation.Field(
+			&category.Color,
+			is.HexColor.Error("色は#RRGGBBの形式で入力してください"),
+		),
+	); err != nil {
+		return err
+	}
+	// 追加項目のキーの重複と型をチェック
+	keys := map[string]bool{}
+	for _, field := range category.Fields {
+		if err := validation.ValidateStruct(&field,
+			validation.Field(
+				&field.Key,
+				validation.Required.Error("追加項目のキーを入力してください"),
+				validation.RuneLength(1, 30).Error("追加項目のキーは30文字以内で入力してください"),
+			),
+			validation.Field(
+				&field.Type,
+				validation.Required.Error("追加項目の型を指定してください"),
+				validation.In(model.FieldTypeText, model.FieldTypeNumber, model.FieldTypeBoolean, model.FieldTypeDate).
+					Error("追加項目の型の指定が正しくありません"),
+			),
+		); err != nil {
+			return err
+		}
+		if keys[field.Key] {
+			return fmt.Errorf("追加項目のキー「%s」が重複しています", field.Key)
+		}
+		keys[field.Key] = true
+	}
+	return nil
+}
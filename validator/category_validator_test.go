@@ -0,0 +1,34 @@
+package validator
+
+import (
+	"bulletin-board-rest-api/model"
+	"testing"
+)
+
+func TestCategoryValidate(t *testing.T) {
+	cv := NewCategoryValidator()
+	tests := []struct {
+		name     string
+		category model.Category
+		wantErr  bool
+	}{
+		{"正しい入力", model.Category{Name: "勉強", Color: "#FF8800"}, false},
+		{"色が未指定", model.Category{Name: "勉強"}, false},
+		{"名前が未入力", model.Category{Color: "#FF8800"}, true},
+		{"色の形式が不正", model.Category{Name: "勉強", Color: "orange"}, true},
+		{"追加項目", model.Category{Name: "勉強", Fields: []model.CategoryField{{Key: "place", Type: model.FieldTypeText}}}, false},
+		{"追加項目の型が不正", model.Category{Name: "勉強", Fields: []model.CategoryField{{Key: "place", Type: "json"}}}, true},
+		{"追加項目のキーが未入力", model.Category{Name: "勉強", Fields: []model.CategoryField{{Type: model.FieldTypeText}}}, true},
+		{"追加項目のキーが重複", model.Category{Name: "勉強", Fields: []model.CategoryField{
+			{Key: "place", Type: model.FieldTypeText}, {Key: "place", Type: model.FieldTypeNumber},
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cv.CategoryValidate(tt.category)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CategoryValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
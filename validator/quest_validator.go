@@ -2,12 +2,15 @@ package validator
 
 import (
 	"bulletin-board-rest-api/model"
+	"fmt"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
 
 type IQuestValidator interface {
-	QuestValidate(quest model.Quest) error //バリデーションで評価したいクエストの構造体を引数に取る
+	QuestValidate(quest model.Quest) error                                                 //バリデーションで評価したいクエストの構造体を引数に取る
+	QuestCustomFieldsValidate(fields model.CustomFields, defs []model.CategoryField) error // カテゴリの追加項目の定義に沿っているか
 }
 
 type questValidator struct{}
@@ -23,5 +26,58 @@ func (qv *questValidator) QuestValidate(quest model.Quest) error {
 			validation.Required.Error("タイトルを入力してください"),
 			validation.RuneLength(1, 20).Error("タイトルは20文字以内で入力してください"),
 		),
+		validation.Field(
+			&quest.Visibility,
+			validation.In(model.VisibilityPublic, model.VisibilityUnlisted, model.VisibilityInviteOnly).
+				Error("公開範囲の指定が正しくありません"),
+		),
+		validation.Field(
+			&quest.TagNames,
+			validation.Length(0, 10).Error("タグは10個以内で指定してください"),
+			validation.Each(validation.RuneLength(1, 20).Error("タグは20文字以内で入力してください")),
+		),
 	)
 }
+
+func (qv *questValidator) QuestCustomFieldsValidate(fields model.CustomFields, defs []model.CategoryField) error {
+	defined := map[string]bool{}
+	for _, def := range defs {
+		defined[def.Key] = true
+		label := def.Label
+		if label == "" {
+			label = def.Key
+		}
+		value, ok := fields[def.Key]
+		if !ok || value == nil || value == "" {
+			if def.Required {
+				return fmt.Errorf("%sを入力してください", label)
+			}
+			continue
+		}
+		// JSONから読み込んだ値の型が定義と一致するか
+		valid := false
+		switch def.Type {
+		case model.FieldTypeText:
+			_, valid = value.(string)
+		case model.FieldTypeNumber:
+			_, valid = value.(float64)
+		case model.FieldTypeBoolean:
+			_, valid = value.(bool)
+		case model.FieldTypeDate:
+			if s, ok := value.(string); ok {
+				_, err := time.Parse("2006-01-02", s)
+				valid = err == nil
+			}
+		}
+		if !valid {
+			return fmt.Errorf("%sの形式が正しくありません", label)
+		}
+	}
+	// 定義されていない項目は受け付けない
+	for key := range fields {
+		if !defined[key] {
+			return fmt.Errorf("追加項目「%s」はこのカテゴリにはありません", key)
+		}
+	}
+	return nil
+}
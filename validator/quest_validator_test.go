@@ -0,0 +1,66 @@
+package validator
+
+import (
+	"bulletin-board-rest-api/model"
+	"strings"
+	"testing"
+)
+
+func TestQuestValidate(t *testing.T) {
+	qv := NewQuestValidator()
+	tests := []struct {
+		name    string
+		quest   model.Quest
+		wantErr bool
+	}{
+		{"正しい入力", model.Quest{Title: "勉強会", Visibility: model.VisibilityPublic}, false},
+		{"公開範囲が未指定", model.Quest{Title: "勉強会"}, false},
+		{"招待制", model.Quest{Title: "勉強会", Visibility: model.VisibilityInviteOnly}, false},
+		{"タイトルが未入力", model.Quest{Visibility: model.VisibilityPublic}, true},
+		{"タイトルが20文字（全角）", model.Quest{Title: strings.Repeat("あ", 20)}, false},
+		{"タイトルが21文字", model.Quest{Title: strings.Repeat("あ", 21)}, true},
+		{"公開範囲が不正", model.Quest{Title: "勉強会", Visibility: "secret"}, true},
+		{"タグが10個", model.Quest{Title: "勉強会", TagNames: strings.Split("a,b,c,d,e,f,g,h,i,j", ",")}, false},
+		{"タグが11個", model.Quest{Title: "勉強会", TagNames: strings.Split("a,b,c,d,e,f,g,h,i,j,k", ",")}, true},
+		{"タグ名が21文字", model.Quest{Title: "勉強会", TagNames: []string{strings.Repeat("a", 21)}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := qv.QuestValidate(tt.quest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QuestValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuestCustomFieldsValidate(t *testing.T) {
+	qv := NewQuestValidator()
+	defs := []model.CategoryField{
+		{Key: "place", Label: "場所", Type: model.FieldTypeText, Required: true},
+		{Key: "fee", Type: model.FieldTypeNumber},
+		{Key: "online", Type: model.FieldTypeBoolean},
+		{Key: "date", Type: model.FieldTypeDate},
+	}
+	tests := []struct {
+		name    string
+		fields  model.CustomFields
+		wantErr bool
+	}{
+		{"必須項目のみ", model.CustomFields{"place": "A棟"}, false},
+		{"全ての項目", model.CustomFields{"place": "A棟", "fee": float64(500), "online": true, "date": "2024-04-01"}, false},
+		{"必須項目が未入力", model.CustomFields{"fee": float64(500)}, true},
+		{"必須項目が空文字", model.CustomFields{"place": ""}, true},
+		{"数値の型が不正", model.CustomFields{"place": "A棟", "fee": "500"}, true},
+		{"日付の形式が不正", model.CustomFields{"place": "A棟", "date": "2024/04/01"}, true},
+		{"定義されていない項目", model.CustomFields{"place": "A棟", "unknown": "x"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := qv.QuestCustomFieldsValidate(tt.fields, defs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QuestCustomFieldsValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
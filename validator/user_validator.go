@@ -13,6 +13,9 @@ import (
 type IUserValidator interface {
 	ValidateUserSignUp(user model.User) error
 	ValidateUserLogIn(user model.User) error
+	ValidateUserProfile(profile model.UpdateProfileRequest) error
+	ValidatePassword(password string) error // サインアップと同じパスワードのルール
+	ValidateNewEmail(email string) error    // サインアップと同じメールアドレスのルール
 }
 
 type userValidator struct{}
@@ -67,3 +70,54 @@ func (uv *userValidator) ValidateUserLogIn(user model.User) error {
 		),
 	)
 }
+
+func (uv *userValidator) ValidateUserProfile(profile model.UpdateProfileRequest) error {
+	return validation.ValidateStruct(&profile,
+		validation.Field(
+			&profile.DisplayName,
+			validation.RuneLength(0, 20).Error("表示名は20文字以内で入力してください"),
+		),
+		validation.Field(
+			&profile.Bio,
+			validation.RuneLength(0, 200).Error("自己紹介は200文字以内で入力してください"),
+		),
+		validation.Field(
+			&profile.Faculty,
+			validation.RuneLength(0, 30).Error("学部・学科は30文字以内で入力してください"),
+		),
+		validation.Field(
+			&profile.Grade,
+			validation.Max(uint(9)).Error("学年の指定が正しくありません"),
+		),
+		validation.Field(
+			&profile.Avatar,
+			validation.Length(0, 1<<20).Error("アイコン画像は1MB以内にしてください"),
+		),
+		validation.Field(
+			&profile.Links,
+			// StringListはdriver.Valuerなので、そのまま渡すとJSON文字列として検証されてしまう
+			validation.By(func(interface{}) error {
+				return validation.Validate([]string(profile.Links),
+					validation.Length(0, 5).Error("リンクは5個以内で入力してください"),
+					validation.Each(is.URL.Error("リンクの形式が正しくありません")),
+				)
+			}),
+		),
+	)
+}
+
+func (uv *userValidator) ValidatePassword(password string) error {
+	return validation.Validate(password,
+		validation.Required.Error("パスワードを入力してください"),
+		validation.RuneLength(6, 20).Error("パスワードは6～20文字以内で入力してください"),
+	)
+}
+
+func (uv *userValidator) ValidateNewEmail(email string) error {
+	return validation.Validate(email,
+		validation.Required.Error("メールアドレスを入力してください"),
+		validation.RuneLength(1, 30).Error("メールアドレスは30文字以内で入力してください"),
+		is.Email.Error("入力されたメールアドレスの形式が適切ではありません"),
+		&allowedEmailRule{},
+	)
+}
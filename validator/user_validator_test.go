@@ -0,0 +1,123 @@
+package validator
+
+import (
+	"bulletin-board-rest-api/model"
+	"net"
+	"strings"
+	"testing"
+)
+
+// is.Emailはドメインの名前解決まで行うので、ネットワークがない環境では学内メールアドレスが通らない
+func skipWithoutDNS(t *testing.T) {
+	t.Helper()
+	if _, err := net.LookupIP("st.pu-toyama.ac.jp"); err != nil {
+		t.Skip("学内メールアドレスのドメインを名前解決できないためスキップ:", err)
+	}
+}
+
+const studentEmail = "s1234567@st.pu-toyama.ac.jp"
+
+func TestValidateUserSignUp(t *testing.T) {
+	uv := NewUserValidator()
+	tests := []struct {
+		name    string
+		user    model.User
+		wantErr bool
+	}{
+		{"学生のメールアドレス", model.User{Email: studentEmail, Password: "secret", UserName: "taro"}, false},
+		{"教職員のメールアドレス", model.User{Email: "t123@puc.pu-toyama.ac.jp", Password: "secret", UserName: "taro"}, false},
+		{"学外のメールアドレス", model.User{Email: "taro@example.com", Password: "secret", UserName: "taro"}, true},
+		{"メールアドレスの形式が不正", model.User{Email: "taro@@st.pu-toyama.ac.jp", Password: "secret", UserName: "taro"}, true},
+		{"メールアドレスが31文字", model.User{Email: "s12345678901@st.pu-toyama.ac.jp", Password: "secret", UserName: "taro"}, true},
+		{"メールアドレスが未入力", model.User{Password: "secret", UserName: "taro"}, true},
+		{"パスワードが5文字", model.User{Email: studentEmail, Password: "short", UserName: "taro"}, true},
+		{"パスワードが21文字", model.User{Email: studentEmail, Password: strings.Repeat("a", 21), UserName: "taro"}, true},
+		{"ユーザー名が10文字（全角）", model.User{Email: studentEmail, Password: "secret", UserName: strings.Repeat("あ", 10)}, false},
+		{"ユーザー名が11文字", model.User{Email: studentEmail, Password: "secret", UserName: strings.Repeat("a", 11)}, true},
+		{"ユーザー名が未入力", model.User{Email: studentEmail, Password: "secret"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.wantErr && strings.HasSuffix(tt.user.Email, ".pu-toyama.ac.jp") {
+				skipWithoutDNS(t)
+			}
+			err := uv.ValidateUserSignUp(tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUserSignUp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUserLogIn(t *testing.T) {
+	uv := NewUserValidator()
+	tests := []struct {
+		name    string
+		user    model.User
+		wantErr bool
+	}{
+		{"正しい入力", model.User{Email: studentEmail, Password: "secret"}, false},
+		{"学外のメールアドレスでもログインの検証は通す", model.User{Email: "taro@example.com", Password: "secret"}, false},
+		{"パスワードが未入力", model.User{Email: studentEmail}, true},
+		{"メールアドレスの形式が不正", model.User{Email: "taro", Password: "secret"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.wantErr && strings.HasSuffix(tt.user.Email, ".pu-toyama.ac.jp") {
+				skipWithoutDNS(t)
+			}
+			err := uv.ValidateUserLogIn(tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUserLogIn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUserProfile(t *testing.T) {
+	uv := NewUserValidator()
+	tests := []struct {
+		name    string
+		profile model.UpdateProfileRequest
+		wantErr bool
+	}{
+		{"未入力", model.UpdateProfileRequest{}, false},
+		{"正しい入力", model.UpdateProfileRequest{DisplayName: "太郎", Grade: 3, Links: model.StringList{"https://example.com"}}, false},
+		{"表示名が21文字", model.UpdateProfileRequest{DisplayName: strings.Repeat("あ", 21)}, true},
+		{"学年が10", model.UpdateProfileRequest{Grade: 10}, true},
+		{"リンクが6個", model.UpdateProfileRequest{Links: model.StringList{"https://a.jp", "https://b.jp", "https://c.jp", "https://d.jp", "https://e.jp", "https://f.jp"}}, true},
+		{"リンクの形式が不正", model.UpdateProfileRequest{Links: model.StringList{"not a url"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := uv.ValidateUserProfile(tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUserProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateNewEmail(t *testing.T) {
+	uv := NewUserValidator()
+	tests := []struct {
+		name    string
+		email   string
+		wantErr bool
+	}{
+		{"学生のメールアドレス", studentEmail, false},
+		{"学外のメールアドレス", "taro@example.com", true},
+		{"未入力", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.wantErr {
+				skipWithoutDNS(t)
+			}
+			err := uv.ValidateNewEmail(tt.email)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateNewEmail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}